@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"airbnb-scraper/jobs"
+	"airbnb-scraper/models"
+)
+
+// maxJobRequestBytes bounds the body of a job start request
+const maxJobRequestBytes = 64 << 10
+
+// handleJobs serves GET /api/jobs, the remembered jobs latest first, and
+// POST /api/jobs, which starts a scrape with the overrides in the body
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": s.jobs.Jobs()})
+		return
+	}
+
+	var overrides models.JobOverrides
+	body := http.MaxBytesReader(w, r.Body, maxJobRequestBytes)
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&overrides); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid job request: %v", err)
+		return
+	}
+
+	job, err := s.jobs.Start(overrides)
+	switch {
+	case errors.Is(err, jobs.ErrJobRunning):
+		writeError(w, http.StatusConflict, "%v", err)
+	case err != nil:
+		writeError(w, http.StatusBadRequest, "%v", err)
+	default:
+		w.Header().Set("Location", "/api/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// handleJob serves GET /api/jobs/{id}, POST /api/jobs/{id}/cancel and
+// GET /api/jobs/{id}/log, where ?tail=N limits the log to its last N lines
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/jobs/"), "/"), "/")
+	id, action := parts[0], ""
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+	if id == "" || len(parts) > 2 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	switch action {
+	case "":
+		if !methodAllowed(w, r, http.MethodGet) {
+			return
+		}
+		job, err := s.jobs.Job(id)
+		if err != nil {
+			writeJobError(w, id, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+
+	case "cancel":
+		if !methodAllowed(w, r, http.MethodPost) {
+			return
+		}
+		job, err := s.jobs.Cancel(id)
+		if err != nil {
+			writeJobError(w, id, err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, job)
+
+	case "log":
+		if !methodAllowed(w, r, http.MethodGet) {
+			return
+		}
+		tail := 0
+		if raw := r.URL.Query().Get("tail"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 {
+				writeError(w, http.StatusBadRequest, "tail must be a positive integer, got %q", raw)
+				return
+			}
+			tail = n
+		}
+		lines, err := s.jobs.Log(id, tail)
+		if err != nil {
+			writeJobError(w, id, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, line := range lines {
+			_, _ = w.Write([]byte(line + "\n"))
+		}
+
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func writeJobError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		writeError(w, http.StatusNotFound, "job %s not found", id)
+	case errors.Is(err, jobs.ErrJobFinished):
+		writeError(w, http.StatusConflict, "job %s has already finished", id)
+	default:
+		writeError(w, http.StatusInternalServerError, "%v", err)
+	}
+}
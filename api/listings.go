@@ -0,0 +1,272 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"airbnb-scraper/models"
+)
+
+// Page size limits for listing queries
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// listingPage is the JSON body of /api/listings
+type listingPage struct {
+	Total    int               `json:"total"` // matches across all pages
+	Limit    int               `json:"limit"`
+	Offset   int               `json:"offset"`
+	Listings []*models.Listing `json:"listings"`
+}
+
+// listingDetail is the JSON body of /api/listings/{id}
+type listingDetail struct {
+	Listing      *models.Listing    `json:"listing"`
+	PriceHistory []*models.Snapshot `json:"price_history"` // oldest first
+}
+
+var listingCSVHeader = []string{
+	"listing_id", "run_id", "title", "city", "location", "property_type", "price", "currency",
+	"rating", "review_count", "beds", "bedrooms", "value_score", "is_outlier", "url", "scraped_at",
+}
+
+func listingCSVRow(l *models.Listing) []string {
+	return []string{
+		l.ListingID, l.RunID, l.Title, l.City, l.Location, string(l.PropertyType),
+		strconv.FormatFloat(l.Price, 'f', 2, 64), l.Currency,
+		strconv.FormatFloat(l.Rating, 'f', 2, 64), strconv.Itoa(l.ReviewCount),
+		strconv.Itoa(l.Beds), strconv.Itoa(l.Bedrooms),
+		strconv.FormatFloat(l.ValueScore, 'f', 2, 64), strconv.FormatBool(l.IsOutlier),
+		l.URL, l.ScrapedAt.Format(time.RFC3339),
+	}
+}
+
+// handleListings serves GET /api/listings: filtered, sorted and paged
+// listings
+func (s *Server) handleListings(w http.ResponseWriter, r *http.Request) {
+	format, err := responseFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	q, err := parseListingQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	if err := parsePage(r.URL.Query(), q); err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	listings, total, err := s.store.QueryListings(q)
+	if err != nil {
+		s.logger.Error("Listing query failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "listing query failed")
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if format == FormatCSV {
+		rows := make([][]string, len(listings))
+		for i, l := range listings {
+			rows[i] = listingCSVRow(l)
+		}
+		writeCSV(w, listingCSVHeader, rows)
+		return
+	}
+	if listings == nil {
+		listings = []*models.Listing{}
+	}
+	writeJSON(w, http.StatusOK, listingPage{Total: total, Limit: q.Limit, Offset: q.Offset, Listings: listings})
+}
+
+// handleListing serves GET /api/listings/{id}: one listing and its price
+// history, or the history alone as CSV
+func (s *Server) handleListing(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/listings/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	format, err := responseFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	listing, err := s.store.GetListing(id)
+	if err != nil {
+		s.logger.Error("Loading listing %s failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "loading listing failed")
+		return
+	}
+	if listing == nil {
+		writeError(w, http.StatusNotFound, "listing %s not found", id)
+		return
+	}
+	history, err := s.store.PriceHistory(id)
+	if err != nil {
+		s.logger.Error("Loading price history of %s failed: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "loading price history failed")
+		return
+	}
+
+	if format == FormatCSV {
+		rows := make([][]string, len(history))
+		for i, h := range history {
+			rows[i] = []string{
+				h.RunID, h.ScrapedAt.Format(time.RFC3339), strconv.FormatFloat(h.Price, 'f', 2, 64),
+				strconv.FormatFloat(h.Rating, 'f', 2, 64), strconv.Itoa(h.ReviewCount), strconv.FormatBool(h.IsOutlier),
+			}
+		}
+		writeCSV(w, []string{"run_id", "scraped_at", "price", "rating", "review_count", "is_outlier"}, rows)
+		return
+	}
+	if history == nil {
+		history = []*models.Snapshot{}
+	}
+	writeJSON(w, http.StatusOK, listingDetail{Listing: listing, PriceHistory: history})
+}
+
+// handleLocations serves GET /api/locations: market statistics per city over
+// the listings matching the same filters as /api/listings
+func (s *Server) handleLocations(w http.ResponseWriter, r *http.Request) {
+	format, err := responseFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	q, err := parseListingQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+
+	listings, _, err := s.store.QueryListings(q)
+	if err != nil {
+		s.logger.Error("Listing query failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "listing query failed")
+		return
+	}
+	stats := s.insights.LocationStats(listings)
+
+	if format == FormatCSV {
+		money := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+		rows := make([][]string, len(stats))
+		for i, g := range stats {
+			rows[i] = []string{
+				g.Key, strconv.Itoa(g.Count), strconv.Itoa(g.PricedCount), money(g.MeanPrice),
+				money(g.MedianPrice), money(g.P10Price), money(g.P25Price), money(g.P75Price), money(g.P90Price),
+				strconv.Itoa(g.RatedCount), money(g.AverageRating), strconv.FormatFloat(g.SuperhostShare, 'f', 3, 64),
+			}
+		}
+		writeCSV(w, []string{"location", "count", "priced_count", "mean_price", "median_price", "p10_price",
+			"p25_price", "p75_price", "p90_price", "rated_count", "average_rating", "superhost_share"}, rows)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"locations": stats})
+}
+
+// handleRuns serves GET /api/runs: the stored runs, latest first
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	format, err := responseFormat(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	runs, err := s.store.Runs()
+	if err != nil {
+		s.logger.Error("Loading runs failed: %v", err)
+		writeError(w, http.StatusInternalServerError, "loading runs failed")
+		return
+	}
+
+	if format == FormatCSV {
+		rows := make([][]string, len(runs))
+		for i, run := range runs {
+			rows[i] = []string{run.RunID, run.StartedAt.Format(time.RFC3339),
+				run.FinishedAt.Format(time.RFC3339), strconv.Itoa(run.Listings)}
+		}
+		writeCSV(w, []string{"run_id", "started_at", "finished_at", "listings"}, rows)
+		return
+	}
+	if runs == nil {
+		runs = []*models.RunSummary{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"runs": runs})
+}
+
+// parseListingQuery reads the listing filters and sort order:
+// location, property_type, min_price, max_price, min_rating, run and sort,
+// where sort is a field name, descending with a leading "-"
+func parseListingQuery(v url.Values) (*models.ListingQuery, error) {
+	q := &models.ListingQuery{
+		Location:     strings.TrimSpace(v.Get("location")),
+		PropertyType: models.PropertyType(strings.TrimSpace(v.Get("property_type"))),
+		RunID:        strings.TrimSpace(v.Get("run")),
+	}
+	for _, f := range []struct {
+		name string
+		dest *float64
+	}{
+		{"min_price", &q.MinPrice},
+		{"max_price", &q.MaxPrice},
+		{"min_rating", &q.MinRating},
+	} {
+		raw := v.Get(f.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%s must be a non-negative number, got %q", f.name, raw)
+		}
+		*f.dest = n
+	}
+	if q.MaxPrice > 0 && q.MinPrice > q.MaxPrice {
+		return nil, fmt.Errorf("min_price %.2f is above max_price %.2f", q.MinPrice, q.MaxPrice)
+	}
+
+	if sort := v.Get("sort"); sort != "" {
+		q.Descending = strings.HasPrefix(sort, "-")
+		q.SortBy = strings.TrimPrefix(sort, "-")
+		known := false
+		for _, f := range models.ListingSortFields {
+			if f == q.SortBy {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("cannot sort by %q (use one of %s)", q.SortBy, strings.Join(models.ListingSortFields, ", "))
+		}
+	}
+	return q, nil
+}
+
+// parsePage reads limit and offset, defaulting to the first page
+func parsePage(v url.Values, q *models.ListingQuery) error {
+	q.Limit = defaultPageSize
+	if raw := v.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPageSize {
+			return fmt.Errorf("limit must be between 1 and %d, got %q", maxPageSize, raw)
+		}
+		q.Limit = n
+	}
+	if raw := v.Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return fmt.Errorf("offset must be a non-negative integer, got %q", raw)
+		}
+		q.Offset = n
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// handleSchedules serves GET /api/schedules: every schedule with its next
+// run and latest recorded run
+func (s *Server) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"schedules": s.scheduler.Schedules()})
+}
+
+// handleSchedule serves GET /api/schedules/{name}: one schedule and its
+// recorded runs, latest first
+func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/schedules/"), "/")
+	status := s.scheduler.Schedule(name)
+	if status == nil {
+		writeError(w, http.StatusNotFound, "schedule %s not found", name)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
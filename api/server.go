@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"airbnb-scraper/jobs"
+	"airbnb-scraper/metrics"
+	"airbnb-scraper/scheduler"
+	"airbnb-scraper/services"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+)
+
+// Response formats, chosen with ?format= or the Accept header
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// Server is the HTTP API: read-only queries over a ListingStore and,
+// when enabled, scrape job control, schedules and metrics
+type Server struct {
+	store     storage.ListingStore
+	insights  *services.InsightService
+	jobs      *jobs.Manager        // nil until EnableJobs
+	scheduler *scheduler.Scheduler // nil until EnableSchedules
+	logger    *utils.Logger
+	mux       *http.ServeMux
+}
+
+// NewServer creates a Server and registers its routes
+func NewServer(store storage.ListingStore, insights *services.InsightService, logger *utils.Logger) *Server {
+	s := &Server{store: store, insights: insights, logger: logger, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/api/listings", allow(s.handleListings, http.MethodGet))
+	s.mux.HandleFunc("/api/listings/", allow(s.handleListing, http.MethodGet))
+	s.mux.HandleFunc("/api/locations", allow(s.handleLocations, http.MethodGet))
+	s.mux.HandleFunc("/api/runs", allow(s.handleRuns, http.MethodGet))
+	return s
+}
+
+// EnableJobs registers the job control endpoints, which start and cancel
+// scrapes through m
+func (s *Server) EnableJobs(m *jobs.Manager) {
+	s.jobs = m
+	s.mux.HandleFunc("/api/jobs", allow(s.handleJobs, http.MethodGet, http.MethodPost))
+	s.mux.HandleFunc("/api/jobs/", s.handleJob)
+}
+
+// EnableSchedules registers the read-only schedule endpoints
+func (s *Server) EnableSchedules(sched *scheduler.Scheduler) {
+	s.scheduler = sched
+	s.mux.HandleFunc("/api/schedules", allow(s.handleSchedules, http.MethodGet))
+	s.mux.HandleFunc("/api/schedules/", allow(s.handleSchedule, http.MethodGet))
+}
+
+// EnableMetrics serves the metrics of r at /metrics in the Prometheus text
+// format
+func (s *Server) EnableMetrics(r *metrics.Registry) {
+	s.mux.Handle("/metrics", allow(r.Handler().ServeHTTP, http.MethodGet))
+}
+
+// Handler returns the handler serving every route, with request logging
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		s.mux.ServeHTTP(rec, r)
+		s.logger.Debug("%s %s → %d (%s)", r.Method, r.URL.RequestURI(), rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// allow rejects methods other than the given ones; GET also allows HEAD
+func allow(h http.HandlerFunc, methods ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !methodAllowed(w, r, methods...) {
+			return
+		}
+		h(w, r)
+	}
+}
+
+// methodAllowed reports whether r uses one of methods, and answers 405
+// when it does not
+func methodAllowed(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m || (m == http.MethodGet && r.Method == http.MethodHead) {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	return false
+}
+
+// statusRecorder remembers the status code written, for the request log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// responseFormat picks JSON or CSV from ?format=, then the Accept header
+func responseFormat(r *http.Request) (string, error) {
+	switch f := strings.ToLower(r.URL.Query().Get("format")); f {
+	case FormatJSON, FormatCSV:
+		return f, nil
+	case "":
+	default:
+		return "", fmt.Errorf("unknown format %q (use json or csv)", f)
+	}
+	if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		return FormatCSV, nil
+	}
+	return FormatJSON, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeCSV(w http.ResponseWriter, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	_ = cw.Write(header)
+	_ = cw.WriteAll(rows)
+}
+
+// writeError sends {"error": "..."} with the given status
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, map[string]string{"error": fmt.Sprintf(format, args...)})
+}
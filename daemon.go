@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"airbnb-scraper/api"
+	"airbnb-scraper/config"
+	"airbnb-scraper/jobs"
+	"airbnb-scraper/metrics"
+	"airbnb-scraper/scheduler"
+	"airbnb-scraper/services"
+	"airbnb-scraper/utils"
+)
+
+// runDaemon scrapes on the schedules in SCHEDULES_FILE until interrupted,
+// serving the query, job and schedule API and the metrics alongside unless
+// -addr is empty. A scheduled run still going at shutdown is cancelled.
+func runDaemon(cfg *config.Config, logger *utils.Logger, args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	schedulesFile := fs.String("schedules", cfg.SchedulesFile, "JSON file of scrape schedules")
+	addr := fs.String("addr", cfg.ServeAddr, "address of the HTTP API; empty disables it")
+	_ = fs.Parse(args)
+
+	schedules, err := scheduler.LoadSchedules(*schedulesFile)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(2)
+	}
+	m := metrics.New()
+	manager := jobs.NewManager(cfg, logger)
+	manager.SetMetrics(m)
+	sched, err := scheduler.New(schedules, manager, cfg.ScheduleHistoryPath, logger)
+	if err != nil {
+		logger.Error("Cannot load schedule history: %v", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	if *addr != "" {
+		store, err := openListingStore(cfg, logger, storePostgres, "")
+		if err != nil {
+			logger.Error("Cannot open listing store: %v", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+
+		server := api.NewServer(store, services.NewInsightService(cfg, logger), logger)
+		server.EnableJobs(manager)
+		server.EnableSchedules(sched)
+		server.EnableMetrics(m.Registry())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Info("Serving the API on %s", *addr)
+			if err := serveHTTP(ctx, *addr, server.Handler()); err != nil {
+				logger.Error("Server failed: %v", err)
+				stop()
+			}
+		}()
+	}
+
+	logger.Info("Daemon started with %d schedules from %s", len(schedules), *schedulesFile)
+	sched.Run(ctx)
+	manager.Stop() // a job started through the API
+	wg.Wait()
+	logger.Info("Daemon stopped")
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"airbnb-scraper/config"
+	"airbnb-scraper/models"
+	"airbnb-scraper/services"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+)
+
+// runDiff compares two scrape runs and reports added, removed and changed
+// listings. Each side is a run ID from the raw history or a raw CSV/JSONL
+// file; without -old and -new the two latest runs in the history are compared.
+func runDiff(cfg *config.Config, logger *utils.Logger, args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	history := fs.String("history", cfg.RawJSONLPath, "raw CSV or JSONL history to look run IDs up in")
+	oldSide := fs.String("old", "", "run ID or raw CSV/JSONL file to compare from (default: latest run before -new)")
+	newSide := fs.String("new", "", "run ID or raw CSV/JSONL file to compare to (default: latest run)")
+	format := fs.String("format", services.DiffFormatText, "output format: text, json or csv")
+	out := fs.String("out", "", "file to write the diff to (default: stdout)")
+	_ = fs.Parse(args)
+
+	// Keep machine-readable stdout free of log lines
+	if *out == "" && *format != services.DiffFormatText {
+		logger = logger.ToStderr()
+	}
+
+	var runs map[string][]*models.RawListing
+	if !isRawFile(*oldSide) || !isRawFile(*newSide) {
+		rawListings, err := storage.ReadRawListings(*history)
+		if err != nil {
+			logger.Error("Cannot load raw history: %v", err)
+			os.Exit(1)
+		}
+		runs = groupByRun(rawListings)
+	}
+	if *newSide == "" {
+		latest := latestRuns(runs)
+		if len(latest) == 0 {
+			logger.Error("No runs in %s to compare; pass -old and -new", *history)
+			os.Exit(2)
+		}
+		*newSide = latest[0]
+	}
+	if *oldSide == "" {
+		previous, err := previousRun(*newSide, runs)
+		if err != nil {
+			logger.Error("%v; pass -old", err)
+			os.Exit(2)
+		}
+		*oldSide = previous
+	}
+
+	cleaner, err := services.NewDataCleaner(cfg, logger)
+	if err != nil {
+		logger.Error("Cannot set up data cleaner: %v", err)
+		os.Exit(1)
+	}
+	load := func(side string) []*models.Listing {
+		raw, err := loadRunSide(side, runs)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+		return cleaner.Clean(raw).Listings
+	}
+	before, after := load(*oldSide), load(*newSide)
+
+	diff := services.DiffRuns(*oldSide, *newSide, before, after, cfg.ReportingCurrency)
+
+	w := os.Stdout
+	if *out != "" {
+		if err := os.MkdirAll(filepath.Dir(*out), 0755); err != nil {
+			logger.Error("Failed to create output directory: %v", err)
+			os.Exit(1)
+		}
+		file, err := os.Create(*out)
+		if err != nil {
+			logger.Error("Failed to create diff file: %v", err)
+			os.Exit(1)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := services.WriteDiff(w, diff, *format); err != nil {
+		logger.Error("Failed to write diff: %v", err)
+		os.Exit(1)
+	}
+	if *out != "" {
+		logger.Info("Diff of %s → %s written to %s", *oldSide, *newSide, *out)
+	}
+}
+
+// isRawFile reports whether a diff side names a raw CSV/JSONL file rather than a run ID
+func isRawFile(side string) bool {
+	switch strings.ToLower(filepath.Ext(side)) {
+	case ".csv", ".jsonl", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// loadRunSide returns the raw listings of a file or of a run in the history
+func loadRunSide(side string, runs map[string][]*models.RawListing) ([]*models.RawListing, error) {
+	if isRawFile(side) {
+		return storage.ReadRawListings(side)
+	}
+	raw, ok := runs[side]
+	if !ok {
+		return nil, fmt.Errorf("run %q not found in raw history", side)
+	}
+	return raw, nil
+}
+
+func groupByRun(listings []*models.RawListing) map[string][]*models.RawListing {
+	runs := make(map[string][]*models.RawListing)
+	for _, l := range listings {
+		if l.RunID != "" {
+			runs[l.RunID] = append(runs[l.RunID], l)
+		}
+	}
+	return runs
+}
+
+// latestRuns returns run IDs, most recently scraped first
+func latestRuns(runs map[string][]*models.RawListing) []string {
+	ids := make([]string, 0, len(runs))
+	for id := range runs {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return lastScraped(runs[ids[i]]) > lastScraped(runs[ids[j]]) })
+	return ids
+}
+
+// previousRun returns the latest run in the history scraped strictly before
+// side, which is a run ID or a raw file
+func previousRun(side string, runs map[string][]*models.RawListing) (string, error) {
+	raw, err := loadRunSide(side, runs)
+	if err != nil {
+		return "", err
+	}
+	cutoff := lastScraped(raw)
+	for _, id := range latestRuns(runs) {
+		if id != side && lastScraped(runs[id]) < cutoff {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("no run in the raw history is older than %s", side)
+}
+
+// lastScraped returns the latest scrape time of listings as a Unix timestamp
+func lastScraped(listings []*models.RawListing) int64 {
+	var t int64
+	for _, l := range listings {
+		if u := l.ScrapedAt.Unix(); u > t {
+			t = u
+		}
+	}
+	return t
+}
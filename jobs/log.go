@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"strings"
+	"sync"
+)
+
+// maxLogLines is how many lines of a job's log are kept; older lines are dropped
+const maxLogLines = 5000
+
+// jobLog collects the log lines of one job. It is written to by every level
+// of the job's logger, possibly from several goroutines.
+type jobLog struct {
+	mu      sync.Mutex
+	lines   []string
+	partial string // text after the last newline
+}
+
+func newJobLog() *jobLog {
+	return &jobLog{}
+}
+
+func (l *jobLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	text := l.partial + string(p)
+	parts := strings.Split(text, "\n")
+	l.partial = parts[len(parts)-1]
+	l.lines = append(l.lines, parts[:len(parts)-1]...)
+	if len(l.lines) > maxLogLines {
+		l.lines = append([]string(nil), l.lines[len(l.lines)-maxLogLines:]...)
+	}
+	return len(p), nil
+}
+
+// Tail returns the last n lines, or every line when n is 0
+func (l *jobLog) Tail(n int) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lines := l.lines
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return append([]string(nil), lines...)
+}
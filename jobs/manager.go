@@ -0,0 +1,305 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"airbnb-scraper/config"
+	"airbnb-scraper/metrics"
+	"airbnb-scraper/models"
+	"airbnb-scraper/pipeline"
+	"airbnb-scraper/utils"
+)
+
+// maxJobs is how many jobs are remembered, running or finished
+const maxJobs = 50
+
+// Errors returned by Manager
+var (
+	ErrJobRunning  = errors.New("a scrape job is already running")
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job has already finished")
+)
+
+// runFunc runs one scrape; pipeline.Run outside of tests
+type runFunc func(ctx context.Context, cfg *config.Config, logger *utils.Logger, opts pipeline.Options) (*pipeline.Result, error)
+
+// Manager starts scrape jobs in the background, one at a time, and keeps
+// their progress and logs
+type Manager struct {
+	cfg     *config.Config
+	logger  *utils.Logger
+	run     runFunc
+	metrics *metrics.Metrics // nil records nothing
+
+	mu     sync.Mutex
+	jobs   []*job // oldest first
+	active *job
+}
+
+// NewManager creates a Manager running jobs with cfg plus their overrides
+func NewManager(cfg *config.Config, logger *utils.Logger) *Manager {
+	return &Manager{cfg: cfg, logger: logger, run: pipeline.Run}
+}
+
+// SetMetrics records the runs of later jobs in m
+func (m *Manager) SetMetrics(mt *metrics.Metrics) {
+	m.metrics = mt
+}
+
+// job is a Job with what is needed to run, cancel and follow it
+type job struct {
+	mu     sync.Mutex
+	info   models.Job
+	cancel context.CancelFunc
+	log    *jobLog
+	done   chan struct{}
+}
+
+// Start validates the overrides and starts a scrape job with them. Only one
+// job runs at a time: while one is running Start returns ErrJobRunning.
+func (m *Manager) Start(overrides models.JobOverrides) (*models.Job, error) {
+	return m.start("", overrides)
+}
+
+// StartScheduled is Start for a run of the named schedule
+func (m *Manager) StartScheduled(schedule string, overrides models.JobOverrides) (*models.Job, error) {
+	return m.start(schedule, overrides)
+}
+
+func (m *Manager) start(schedule string, overrides models.JobOverrides) (*models.Job, error) {
+	cfg, err := m.jobConfig(overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.active != nil {
+		return nil, ErrJobRunning
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		info: models.Job{
+			ID:        utils.NewRunID(),
+			Status:    models.JobRunning,
+			Schedule:  schedule,
+			Overrides: overrides,
+			StartedAt: time.Now(),
+		},
+		cancel: cancel,
+		log:    newJobLog(),
+		done:   make(chan struct{}),
+	}
+	m.jobs = append(m.jobs, j)
+	if len(m.jobs) > maxJobs {
+		m.jobs = m.jobs[len(m.jobs)-maxJobs:]
+	}
+	m.active = j
+
+	m.logger.Info("Starting scrape job %s", j.info.ID)
+	go m.runJob(ctx, cfg, j)
+	return j.snapshot(), nil
+}
+
+// jobConfig is the configuration with a job's overrides applied
+func (m *Manager) jobConfig(o models.JobOverrides) (*config.Config, error) {
+	cfg := *m.cfg
+	if len(o.Sections) > 0 {
+		cfg.Sections = o.Sections
+	}
+	if o.PropertiesPerSection < 0 {
+		return nil, fmt.Errorf("properties_per_section must be positive, got %d", o.PropertiesPerSection)
+	}
+	if o.PropertiesPerSection > 0 {
+		cfg.PropertiesPerPage = o.PropertiesPerSection
+	}
+
+	var checkIn, checkOut time.Time
+	var err error
+	if o.CheckIn != "" {
+		if checkIn, err = time.Parse("2006-01-02", o.CheckIn); err != nil {
+			return nil, fmt.Errorf("check_in must be YYYY-MM-DD, got %q", o.CheckIn)
+		}
+		cfg.SearchCheckIn = o.CheckIn
+	}
+	if o.CheckOut != "" {
+		if checkOut, err = time.Parse("2006-01-02", o.CheckOut); err != nil {
+			return nil, fmt.Errorf("check_out must be YYYY-MM-DD, got %q", o.CheckOut)
+		}
+		cfg.SearchCheckOut = o.CheckOut
+	}
+	if !checkIn.IsZero() && !checkOut.IsZero() && !checkOut.After(checkIn) {
+		return nil, fmt.Errorf("check_out %s must be after check_in %s", o.CheckOut, o.CheckIn)
+	}
+	return &cfg, nil
+}
+
+func (m *Manager) runJob(ctx context.Context, cfg *config.Config, j *job) {
+	defer close(j.done)
+	logger := m.logger.Tee(j.log)
+
+	result, err := m.run(ctx, cfg, logger, pipeline.Options{
+		RunID:    j.info.ID,
+		Progress: j,
+		Metrics:  m.metrics,
+		OnStage: func(stage string) {
+			j.mu.Lock()
+			j.info.Progress.Stage = stage
+			j.mu.Unlock()
+		},
+	})
+
+	j.mu.Lock()
+	now := time.Now()
+	j.info.FinishedAt = &now
+	switch {
+	case errors.Is(err, context.Canceled):
+		j.info.Status = models.JobCanceled
+	case err != nil:
+		j.info.Status = models.JobFailed
+		j.info.Error = err.Error()
+	default:
+		j.info.Status = models.JobSucceeded
+		j.info.CleanListings = len(result.Clean.Listings)
+	}
+	status := j.info.Status
+	j.mu.Unlock()
+	j.cancel()
+
+	m.mu.Lock()
+	m.active = nil
+	m.mu.Unlock()
+	m.logger.Info("Scrape job %s %s", j.info.ID, status)
+}
+
+// Jobs returns every remembered job, latest first
+func (m *Manager) Jobs() []*models.Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*models.Job, 0, len(m.jobs))
+	for i := len(m.jobs) - 1; i >= 0; i-- {
+		list = append(list, m.jobs[i].snapshot())
+	}
+	return list
+}
+
+// Job returns a job by ID
+func (m *Manager) Job(id string) (*models.Job, error) {
+	j, err := m.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return j.snapshot(), nil
+}
+
+// Cancel stops a running job. A scrape stops at the next page; later, the
+// run stops before anything goes to PostgreSQL. Either way the job ends
+// canceled. A job whose listings are already stored finishes its report and
+// ends succeeded.
+func (m *Manager) Cancel(id string) (*models.Job, error) {
+	j, err := m.find(id)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-j.done:
+		return nil, ErrJobFinished
+	default:
+	}
+	m.logger.Info("Cancelling scrape job %s", id)
+	j.cancel()
+	return j.snapshot(), nil
+}
+
+// Wait blocks until a job has finished and returns it, or returns ctx.Err()
+// when ctx is done first
+func (m *Manager) Wait(ctx context.Context, id string) (*models.Job, error) {
+	j, err := m.find(id)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-j.done:
+		return j.snapshot(), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Log returns the last n lines of a job's log, or all of them when n is 0
+func (m *Manager) Log(id string, n int) ([]string, error) {
+	j, err := m.find(id)
+	if err != nil {
+		return nil, err
+	}
+	return j.log.Tail(n), nil
+}
+
+// Stop cancels the running job, if any, and waits for it to finish
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	active := m.active
+	m.mu.Unlock()
+	if active != nil {
+		active.cancel()
+		<-active.done
+	}
+}
+
+func (m *Manager) find(id string) (*job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.info.ID == id {
+			return j, nil
+		}
+	}
+	return nil, ErrJobNotFound
+}
+
+// snapshot copies the job's state for callers outside the job
+func (j *job) snapshot() *models.Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := j.info
+	return &info
+}
+
+// Progress methods, called by the scraper as it runs
+
+func (j *job) SectionsPlanned(names []string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Progress.SectionsTotal = len(names)
+}
+
+func (j *job) PageFetched(section string, page, cards int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Progress.CurrentSection = section
+	j.info.Progress.PagesFetched++
+}
+
+func (j *job) ListingCollected(section string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Progress.Listings++
+}
+
+func (j *job) SectionDone(section string, collected int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Progress.SectionsDone++
+}
+
+func (j *job) Error(section string, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.info.Progress.Errors++
+	j.info.Progress.LastError = fmt.Sprintf("%s: %v", section, err)
+}
@@ -1,76 +1,141 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"airbnb-scraper/config"
-	"airbnb-scraper/scraper/airbnb"
+	"airbnb-scraper/metrics"
+	"airbnb-scraper/models"
+	"airbnb-scraper/pipeline"
 	"airbnb-scraper/services"
-	"airbnb-scraper/storage"
 	"airbnb-scraper/utils"
 )
 
 func main() {
 	// ================== Bootstrap ====================
-	logger := utils.NewLogger()
 	cfg := config.Load()
-
-	logger.Info("Airbnb Rental Scraping System")
-
-	logger.Info("Properties per section: %d", cfg.PropertiesPerPage)
-	logger.Info("Concurrency: %d | Rate delay: %dms | Retries: %d",
-		cfg.MaxConcurrency, cfg.RateLimitDelay, cfg.MaxRetries)
-
-	// =================== PostgreSQL Setup ========================================
-	pgWriter, err := storage.NewPostgresWriter(cfg.DatabaseURL, logger)
+	logger, err := utils.OpenLogger(utils.LoggerOptions{Level: cfg.LogLevel, Format: cfg.LogFormat, File: cfg.LogFile})
 	if err != nil {
-		logger.Error("Cannot connect to PostgreSQL: %v", err)
-		logger.Error("Make sure Docker is running: docker start my-postgres")
-		os.Exit(1)
+		utils.NewLogger().Error("Invalid logging settings: %v", err)
+		os.Exit(2)
 	}
-	defer pgWriter.Close()
+	defer logger.Close()
 
-	if err := pgWriter.CreateTable(); err != nil {
-		logger.Error("Failed to create DB table: %v", err)
-		os.Exit(1)
+	// The first non-flag argument selects a command; plain runs scrape
+	command := "scrape"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "scrape":
+		runScrape(cfg, logger, args)
+	case "reextract":
+		runReextract(cfg, logger, args)
+	case "reprocess":
+		runReprocess(cfg, logger, args)
+	case "diff":
+		runDiff(cfg, logger, args)
+	case "serve":
+		runServe(cfg, logger, args)
+	case "daemon":
+		runDaemon(cfg, logger, args)
+	case "queue":
+		runQueue(cfg, logger, args)
+	case "worker":
+		runWorker(cfg, logger, args)
+	default:
+		logger.Error("Unknown command %q (available: scrape, reextract, reprocess, diff, serve, daemon, queue, worker)", command)
+		os.Exit(2)
 	}
+}
 
-	// =============== Scraping ===================================
-	scraper := airbnb.NewAirbnbScraper(cfg, logger)
-	rawListings, err := scraper.Scrape()
+// runScrape is the default pipeline: scrape → CSV → clean → PostgreSQL → insights
+func runScrape(cfg *config.Config, logger *utils.Logger, args []string) {
+	fs := flag.NewFlagSet("scrape", flag.ExitOnError)
+	fs.StringVar(&cfg.ReportFormat, "report-format", cfg.ReportFormat, "insight report format: text, json, markdown or html")
+	fs.StringVar(&cfg.ReportPath, "report-out", cfg.ReportPath, "file to write the insight report to (default: print it)")
+	_ = fs.Parse(args)
+
+	reporter, err := services.NewReporter(cfg.ReportFormat)
 	if err != nil {
-		logger.Error("Scraping failed: %v", err)
-		os.Exit(1)
+		logger.Error("%v", err)
+		os.Exit(2)
+	}
+	// Keep a machine-readable report on stdout free of log lines and summaries
+	reportOnStdout := cfg.ReportPath == "" && cfg.ReportFormat != "" &&
+		!strings.EqualFold(cfg.ReportFormat, services.ReportFormatText)
+	if reportOnStdout {
+		logger = logger.ToStderr()
 	}
 
-	if len(rawListings) == 0 {
+	m := metrics.New()
+	result, err := pipeline.Run(context.Background(), cfg, logger, pipeline.Options{Metrics: m})
+	writeMetrics(cfg, logger, m)
+	if errors.Is(err, pipeline.ErrNoListings) {
 		logger.Warn("No listings scraped — check your network connection or Airbnb page structure")
 		os.Exit(0)
 	}
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
 
-	// ========= CSV: store raw data ===========================
-	csvWriter := storage.NewCSVWriter(cfg.CSVFilePath, logger)
-	if err := csvWriter.WriteRawListings(rawListings); err != nil {
-		logger.Error("Failed to write CSV: %v", err)
-		// Non-fatal: continue to DB storage
+	writeReport(cfg, logger, reporter, result.Report)
+	if reportOnStdout {
+		return
 	}
+	services.PrintValidationSummary(result.Clean.Stats)
 
-	// =========== Data Cleaning ======================
-	cleaner := services.NewDataCleaner(logger)
-	cleanListings := cleaner.Clean(rawListings)
+	fmt.Println(" Done! Raw data →", cfg.CSVFilePath)
+	fmt.Println(" Clean data stored in PostgreSQL table: alldata")
+}
 
-	// ========= PostgreSQL: store clean data ============
-	if err := pgWriter.BatchInsert(cleanListings); err != nil {
-		logger.Error("Failed to insert into PostgreSQL: %v", err)
-		os.Exit(1)
+// writeMetrics writes the metrics of a one-shot run to METRICS_FILE, where
+// the node_exporter textfile collector or a push job can pick them up
+func writeMetrics(cfg *config.Config, logger *utils.Logger, m *metrics.Metrics) {
+	if cfg.MetricsFile == "" {
+		return
+	}
+	if err := m.Registry().WriteFile(cfg.MetricsFile); err != nil {
+		logger.Error("%v", err)
+		return
 	}
+	logger.Info("Metrics written to: %s", cfg.MetricsFile)
+}
 
-	// ==== Insights ============================
-	insightSvc := services.NewInsightService(logger)
-	report := insightSvc.Generate(cleanListings)
-	services.PrintInsightReport(report)
+// writeReport prints the insight report, or writes it to REPORT_PATH. The
+// terminal always gets a readable report: a file report is written alongside
+// the text one, and only a non-text report without a path replaces it.
+func writeReport(cfg *config.Config, logger *utils.Logger, reporter services.Reporter, report *models.InsightReport) {
+	if cfg.ReportPath == "" {
+		if err := reporter.Render(os.Stdout, report); err != nil {
+			logger.Error("Failed to print insight report: %v", err)
+		}
+		return
+	}
 
-	fmt.Println(" Done! Raw data →", cfg.CSVFilePath)
-	fmt.Println(" Clean data stored in PostgreSQL table: alldata")
-}
\ No newline at end of file
+	services.PrintInsightReport(report)
+	if err := os.MkdirAll(filepath.Dir(cfg.ReportPath), 0755); err != nil {
+		logger.Error("Failed to create report directory: %v", err)
+		return
+	}
+	file, err := os.Create(cfg.ReportPath)
+	if err != nil {
+		logger.Error("Failed to create report file: %v", err)
+		return
+	}
+	defer file.Close()
+	if err := reporter.Render(file, report); err != nil {
+		logger.Error("Failed to write insight report: %v", err)
+		return
+	}
+	logger.Info("Insight report written to: %s", cfg.ReportPath)
+}
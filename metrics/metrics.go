@@ -0,0 +1,194 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Page kinds of PageFetched
+const (
+	PageHomepage = "homepage"
+	PageSearch   = "search"
+	PageDetail   = "detail"
+)
+
+// Run results of RunFinished
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunCanceled  = "canceled"
+)
+
+// latencyBuckets are upper bounds in seconds for page navigation
+var latencyBuckets = []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60}
+
+// waitBuckets are upper bounds in seconds for rate limiter waits
+var waitBuckets = []float64{0.01, 0.1, 0.5, 1, 2, 5, 10}
+
+// runBuckets are upper bounds in seconds for whole runs
+var runBuckets = []float64{60, 300, 600, 1200, 1800, 3600, 7200}
+
+// Metrics are the scraper and pipeline metrics of one process. Every method
+// is safe on a nil *Metrics, which records nothing, so instrumented code
+// does not have to check whether metrics are on.
+type Metrics struct {
+	registry *Registry
+
+	pagesFetched       *Counter
+	navigationSeconds  *Histogram
+	extractionStrategy *Counter
+	sectionListings    *Gauge
+	listingsScraped    *Counter
+	retries            *Counter
+	rateLimitWait      *Histogram
+	cleanerListings    *Counter
+	cleanerDropped     *Counter
+	dbRows             *Counter
+	runs               *Counter
+	runDuration        *Histogram
+	lastRunDuration    *Gauge
+	lastRunTimestamp   *Gauge
+	lastRunListings    *Gauge
+}
+
+// New creates the metrics in a new Registry
+func New() *Metrics {
+	r := NewRegistry()
+	return &Metrics{
+		registry: r,
+		pagesFetched: r.Counter("airbnb_pages_fetched_total",
+			"Pages navigated to, by kind (homepage, search, detail) and result (ok, error).", "kind", "result"),
+		navigationSeconds: r.Histogram("airbnb_navigation_seconds",
+			"Time to navigate to a page, by kind.", latencyBuckets, "kind"),
+		extractionStrategy: r.Counter("airbnb_extraction_strategy_total",
+			"Search pages by the card selector strategy that found listings: A (test id), B (itemprop), C (room links) or none.", "strategy"),
+		sectionListings: r.Gauge("airbnb_section_listings",
+			"Listings collected from a section in its latest scrape.", "section"),
+		listingsScraped: r.Counter("airbnb_listings_scraped_total",
+			"Raw listings collected, by section.", "section"),
+		retries: r.Counter("airbnb_retries_total",
+			"Retried attempts, by operation.", "operation"),
+		rateLimitWait: r.Histogram("airbnb_rate_limit_wait_seconds",
+			"Time spent waiting for the rate limiter before a request.", waitBuckets),
+		cleanerListings: r.Counter("airbnb_cleaner_listings_total",
+			"Cleaned listings by validation status (accepted, warned, rejected).", "status"),
+		cleanerDropped: r.Counter("airbnb_cleaner_dropped_total",
+			"Raw listings the cleaner dropped, by reason: duplicate, or the code of a rejecting validation rule.", "reason"),
+		dbRows: r.Counter("airbnb_db_insert_rows_total",
+			"Clean listings offered to PostgreSQL, by result (inserted, updated, stale, skipped, failed).", "result"),
+		runs: r.Counter("airbnb_runs_total",
+			"Pipeline runs by result (succeeded, failed, canceled).", "result"),
+		runDuration: r.Histogram("airbnb_run_duration_seconds",
+			"Duration of pipeline runs, by result.", runBuckets, "result"),
+		lastRunDuration: r.Gauge("airbnb_last_run_duration_seconds",
+			"Duration of the latest pipeline run."),
+		lastRunTimestamp: r.Gauge("airbnb_last_run_timestamp_seconds",
+			"Unix time the latest pipeline run finished, by result.", "result"),
+		lastRunListings: r.Gauge("airbnb_last_run_clean_listings",
+			"Clean listings of the latest successful run."),
+	}
+}
+
+// Registry returns the registry holding the metrics, to serve or write them
+func (m *Metrics) Registry() *Registry {
+	if m == nil {
+		return nil
+	}
+	return m.registry
+}
+
+// PageFetched records a navigation to a page of the given kind
+func (m *Metrics) PageFetched(kind string, took time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.pagesFetched.Inc(kind, result)
+	m.navigationSeconds.Observe(took.Seconds(), kind)
+}
+
+// ExtractionStrategy records which card selector strategy a search page used
+func (m *Metrics) ExtractionStrategy(strategy string) {
+	if m == nil {
+		return
+	}
+	if strategy == "" {
+		strategy = "none"
+	}
+	m.extractionStrategy.Inc(strategy)
+}
+
+// SectionScraped records the listings collected from a section
+func (m *Metrics) SectionScraped(section string, listings int) {
+	if m == nil {
+		return
+	}
+	m.sectionListings.Set(float64(listings), section)
+	m.listingsScraped.Add(float64(listings), section)
+}
+
+// Retry records a retried attempt of an operation
+func (m *Metrics) Retry(operation string) {
+	if m == nil {
+		return
+	}
+	m.retries.Inc(operation)
+}
+
+// RateLimitWait records time spent waiting for the rate limiter
+func (m *Metrics) RateLimitWait(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.rateLimitWait.Observe(d.Seconds())
+}
+
+// Cleaned records how many cleaned listings got a validation status
+func (m *Metrics) Cleaned(status string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.cleanerListings.Add(float64(n), status)
+}
+
+// Dropped records raw listings the cleaner dropped for a reason
+func (m *Metrics) Dropped(reason string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.cleanerDropped.Add(float64(n), reason)
+}
+
+// Stored records clean listings offered to PostgreSQL with a result
+func (m *Metrics) Stored(result string, n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.dbRows.Add(float64(n), result)
+}
+
+// RunFinished records a pipeline run that took the given time and ended
+// with err, and its clean listings when it succeeded
+func (m *Metrics) RunFinished(took time.Duration, cleanListings int, err error) {
+	if m == nil {
+		return
+	}
+	result := RunSucceeded
+	switch {
+	case errors.Is(err, context.Canceled):
+		result = RunCanceled
+	case err != nil:
+		result = RunFailed
+	}
+	m.runs.Inc(result)
+	m.runDuration.Observe(took.Seconds(), result)
+	m.lastRunDuration.Set(took.Seconds())
+	m.lastRunTimestamp.Set(float64(time.Now().Unix()), result)
+	if err == nil {
+		m.lastRunListings.Set(float64(cleanListings))
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Alert is one listing that matched one alert rule in a run
+type Alert struct {
+	Rule          string    `json:"rule"`
+	RunID         string    `json:"run_id"`
+	ListingID     string    `json:"listing_id"`
+	Title         string    `json:"title"`
+	Location      string    `json:"location"`
+	URL           string    `json:"url"`
+	Currency      string    `json:"currency"` // reporting currency of the prices
+	Price         float64   `json:"price"`
+	PreviousPrice float64   `json:"previous_price,omitempty"` // price in the last run, when known
+	Rating        float64   `json:"rating,omitempty"`
+	Reasons       []string  `json:"reasons"` // the conditions that matched, e.g. "price 38.00 < 40.00"
+	TriggeredAt   time.Time `json:"triggered_at"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// Histogram counts values in equal-width buckets. Bucket i covers
+// [Start + i·Width, Start + (i+1)·Width).
+type Histogram struct {
+	Key    string  `json:"key"` // location, or empty for all listings
+	Start  float64 `json:"start"`
+	Width  float64 `json:"width"`
+	Counts []int   `json:"counts"`
+	Total  int     `json:"total"`
+}
+
+// ScatterPoint is one listing's nightly price and rating
+type ScatterPoint struct {
+	Price  float64 `json:"price"`
+	Rating float64 `json:"rating"`
+}
+
+// PriceRatingScatter relates nightly prices to ratings over listings that
+// have both
+type PriceRatingScatter struct {
+	Points      []ScatterPoint `json:"points"`
+	Correlation float64        `json:"correlation"` // Spearman rank correlation, -1..1
+}
@@ -0,0 +1,41 @@
+package models
+
+// Kinds of change between two runs
+const (
+	ChangeAdded       = "added"
+	ChangeRemoved     = "removed"
+	ChangePrice       = "price_changed"
+	ChangeRating      = "rating_changed"
+	ChangeDescription = "description_changed"
+)
+
+// RunDiff lists what changed between two runs, grouped by location
+type RunDiff struct {
+	Old       string          `json:"old"` // run ID or file compared from
+	New       string          `json:"new"`
+	Currency  string          `json:"currency"`
+	Counts    map[string]int  `json:"counts"` // changes per kind
+	Locations []*LocationDiff `json:"locations"`
+}
+
+// LocationDiff holds the changes of one location
+type LocationDiff struct {
+	Location string           `json:"location"`
+	Changes  []*ListingChange `json:"changes"`
+}
+
+// ListingChange is one change to one listing. A listing whose price and
+// rating both changed has two entries. Added listings only have the new
+// price and rating, removed listings only the old ones.
+type ListingChange struct {
+	Kind           string  `json:"kind"`
+	ListingID      string  `json:"listing_id"`
+	Title          string  `json:"title"`
+	URL            string  `json:"url"`
+	OldPrice       float64 `json:"old_price,omitempty"`
+	NewPrice       float64 `json:"new_price,omitempty"`
+	PriceDelta     float64 `json:"price_delta,omitempty"`
+	PriceChangePct float64 `json:"price_change_pct,omitempty"`
+	OldRating      float64 `json:"old_rating,omitempty"`
+	NewRating      float64 `json:"new_rating,omitempty"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Job statuses
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCanceled  = "canceled"
+)
+
+// JobOverrides adjusts the configuration of one scrape job. Zero values keep
+// the configured defaults.
+type JobOverrides struct {
+	Sections             []string `json:"sections,omitempty"` // place names or search URLs
+	PropertiesPerSection int      `json:"properties_per_section,omitempty"`
+	CheckIn              string   `json:"check_in,omitempty"` // YYYY-MM-DD
+	CheckOut             string   `json:"check_out,omitempty"`
+}
+
+// JobProgress counts what a job has done so far
+type JobProgress struct {
+	Stage          string `json:"stage"` // scraping, cleaning, storing or reporting
+	SectionsTotal  int    `json:"sections_total"`
+	SectionsDone   int    `json:"sections_done"`
+	CurrentSection string `json:"current_section,omitempty"`
+	PagesFetched   int    `json:"pages_fetched"`
+	Listings       int    `json:"listings"` // raw listings collected
+	Errors         int    `json:"errors"`
+	LastError      string `json:"last_error,omitempty"`
+}
+
+// Job is a scrape run started through the job API or by a schedule
+type Job struct {
+	ID            string       `json:"id"` // also the run ID of the scrape
+	Status        string       `json:"status"`
+	Schedule      string       `json:"schedule,omitempty"` // the schedule that started it
+	Overrides     JobOverrides `json:"overrides"`
+	Progress      JobProgress  `json:"progress"`
+	CleanListings int          `json:"clean_listings"` // stored once the job succeeds
+	Error         string       `json:"error,omitempty"`
+	StartedAt     time.Time    `json:"started_at"`
+	FinishedAt    *time.Time   `json:"finished_at,omitempty"`
+}
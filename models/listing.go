@@ -4,37 +4,135 @@ import "time"
 
 // RawListing represents unprocessed data scraped directly from Airbnb
 type RawListing struct {
-	Platform    string
-	Title       string
-	RawPrice    string // e.g. "$71 for 2 nights"
-	Location    string
-	RawRating   string // e.g. "4.82"
-	URL         string
-	Description string
-	ScrapedAt   time.Time
+	RunID       string    `json:"run_id"`
+	Platform    string    `json:"platform"`
+	Title       string    `json:"title"`
+	RawPrice    string    `json:"raw_price"` // e.g. "$71 for 2 nights"
+	Location    string    `json:"location"`
+	Section     string    `json:"section"`    // location section the card was found in
+	Subtitle    string    `json:"subtitle"`   // card subtitle lines, e.g. "2 bedrooms · 3 beds"
+	RawRating   string    `json:"raw_rating"` // e.g. "4.82"
+	URL         string    `json:"url"`
+	PhotoURL    string    `json:"photo_url,omitempty"` // first card image
+	Superhost   bool      `json:"superhost,omitempty"` // card shows a Superhost badge
+	Description string    `json:"description"`
+	ScrapedAt   time.Time `json:"scraped_at"`
+	PageHash    string    `json:"page_hash,omitempty"`   // archive hash of the search page the card was read from
+	DetailHash  string    `json:"detail_hash,omitempty"` // archive hash of the detail page used for enrichment
 }
 
 // Listing represents a cleaned, normalized listing ready for DB storage
 type Listing struct {
-	ID          int64
-	Platform    string
-	Title       string
-	Price       float64 // price per night normalized
-	Location    string
-	Rating      float64
-	URL         string
-	Description string
-	ScrapedAt   time.Time
+	ID          int64     `json:"id"`
+	ListingID   string    `json:"listing_id"` // numeric Airbnb room ID, the dedupe key
+	RunID       string    `json:"run_id"`
+	Platform    string    `json:"platform"`
+	Title       string    `json:"title"`
+	Price       float64   `json:"price"`       // price per night, converted to the reporting currency
+	Currency    string    `json:"currency"`    // ISO 4217 code of the price as shown on Airbnb
+	LocalPrice  float64   `json:"local_price"` // price per night in Currency
+	Location    string    `json:"location"`
+	Rating      float64   `json:"rating"`
+	ReviewCount int       `json:"review_count"`
+	IsNew       bool      `json:"is_new"` // no rating yet, shown as "New"
+	IsSuperhost bool      `json:"is_superhost"`
+	IsOutlier   bool      `json:"is_outlier"`  // nightly price far outside its location's range
+	ValueScore  float64   `json:"value_score"` // 0-100, how much the listing offers for its price locally
+	URL         string    `json:"url"`         // canonical URL without query string
+	Description string    `json:"description"` // raw, as scraped
+	ScrapedAt   time.Time `json:"scraped_at"`
+
+	// Description normalized with emails, phone numbers and URLs redacted,
+	// and its detected ISO 639-1 language ("" when unknown)
+	DescriptionClean string `json:"description_clean"`
+	DescriptionLang  string `json:"description_lang"`
+
+	// Price breakdown as shown on the card, in Currency
+	PreDiscountPrice float64 `json:"pre_discount_price"` // crossed-out nightly price, 0 when not discounted
+	DiscountPct      float64 `json:"discount_pct"`
+	TotalPrice       float64 `json:"total_price"` // total stay price, 0 when not shown
+	Nights           int     `json:"nights"`
+	TaxesIncluded    bool    `json:"taxes_included"`
+
+	// Property details parsed from the card
+	PropertyType PropertyType `json:"property_type"`
+	Beds         int          `json:"beds"`
+	Bedrooms     int          `json:"bedrooms"`
+
+	// Location normalized through the gazetteer. Unknown places keep the raw
+	// neighborhood and section city and leave the rest empty.
+	Neighborhood string  `json:"neighborhood"` // e.g. "Shinjuku" for "Room in Shinjuku City"
+	City         string  `json:"city"`
+	Region       string  `json:"region"`
+	Country      string  `json:"country"`
+	CountryCode  string  `json:"country_code"` // ISO 3166-1 alpha-2
+	Latitude     float64 `json:"latitude"`     // 0,0 when unknown
+	Longitude    float64 `json:"longitude"`
+
+	// Validation outcome: ValidationAccepted or ValidationWarned (rejected
+	// listings are quarantined instead) and the codes of the rules it failed
+	ValidationStatus string   `json:"validation_status"`
+	ValidationCodes  []string `json:"validation_codes,omitempty"`
+
+	// Near-duplicate clustering: listings that look like the same property
+	// under different room IDs share a ClusterID; one of them is canonical.
+	// Listings without suspected duplicates have no ClusterID and are canonical.
+	PhotoURL    string `json:"photo_url"`
+	PhotoHash   string `json:"photo_hash"` // identity of the card photo, see services.photoHash
+	ClusterID   string `json:"cluster_id"`
+	IsCanonical bool   `json:"is_canonical"`
 }
 
 // InsightReport holds computed analytics from the final dataset
 type InsightReport struct {
-	TotalListings      int
-	AirbnbListings     int
-	AveragePrice       float64
-	MinPrice           float64
-	MaxPrice           float64
-	MostExpensive      *Listing
-	TopRated           []*Listing
-	ListingsByLocation map[string]int
-}
\ No newline at end of file
+	Currency            string               `json:"currency"` // reporting currency of all prices below
+	TotalListings       int                  `json:"total_listings"`
+	CollapsedDuplicates int                  `json:"collapsed_duplicates"` // near-duplicates left out of the figures below
+	AirbnbListings      int                  `json:"airbnb_listings"`
+	AveragePrice        float64              `json:"average_price"`
+	MinPrice            float64              `json:"min_price"`
+	MaxPrice            float64              `json:"max_price"`
+	MostExpensive       *Listing             `json:"most_expensive,omitempty"`
+	TopRated            []*RankedListing     `json:"top_rated,omitempty"`            // ranked by review-weighted rating
+	BestValue           []*LocationRanking   `json:"best_value,omitempty"`           // ranked by value score, per city
+	ListingsByLocation  map[string]int       `json:"listings_by_location,omitempty"` // keyed by normalized city
+	ListingsByType      map[PropertyType]int `json:"listings_by_type,omitempty"`
+	LocationStats       []*GroupStats        `json:"location_stats,omitempty"`   // per city, largest first
+	TypeStats           []*GroupStats        `json:"type_stats,omitempty"`       // per property type, largest first
+	Outliers            []*Listing           `json:"outliers,omitempty"`         // flagged price outliers, most expensive first
+	OutliersExcluded    bool                 `json:"outliers_excluded"`          // whether the price figures above leave them out
+	Trends              *TrendReport         `json:"trends,omitempty"`           // nil until at least two runs are stored
+	PriceHistograms     []*Histogram         `json:"price_histograms,omitempty"` // nightly prices per city, largest first
+	RatingHistogram     *Histogram           `json:"rating_histogram,omitempty"`
+	PriceRating         *PriceRatingScatter  `json:"price_rating,omitempty"`
+}
+
+// GroupStats summarizes the nightly prices and ratings of a group of listings.
+// Price figures only use listings with a known price, rating figures only
+// rated listings.
+type GroupStats struct {
+	Key            string  `json:"key"` // city or property type label
+	Count          int     `json:"count"`
+	PricedCount    int     `json:"priced_count"`
+	MeanPrice      float64 `json:"mean_price"`
+	MedianPrice    float64 `json:"median_price"`
+	P10Price       float64 `json:"p10_price"`
+	P25Price       float64 `json:"p25_price"`
+	P75Price       float64 `json:"p75_price"`
+	P90Price       float64 `json:"p90_price"`
+	RatedCount     int     `json:"rated_count"`
+	AverageRating  float64 `json:"average_rating"`
+	SuperhostShare float64 `json:"superhost_share"` // 0..1 of Count
+}
+
+// LocationRanking is the best ranked listings of one location
+type LocationRanking struct {
+	Location string           `json:"location"`
+	Listings []*RankedListing `json:"listings"`
+}
+
+// RankedListing pairs a listing with the score it was ranked by
+type RankedListing struct {
+	Listing *Listing `json:"listing"`
+	Score   float64  `json:"score"`
+}
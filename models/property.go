@@ -0,0 +1,37 @@
+package models
+
+import "strings"
+
+// PropertyType is the normalized kind of place a listing offers
+type PropertyType string
+
+// Normalized property types
+const (
+	PropertyEntireHome        PropertyType = "entire_home"
+	PropertyApartment         PropertyType = "apartment"
+	PropertyServicedApartment PropertyType = "serviced_apartment"
+	PropertyCondo             PropertyType = "condo"
+	PropertyHouse             PropertyType = "house"
+	PropertyTownhouse         PropertyType = "townhouse"
+	PropertyVilla             PropertyType = "villa"
+	PropertyLoft              PropertyType = "loft"
+	PropertyCabin             PropertyType = "cabin"
+	PropertyBungalow          PropertyType = "bungalow"
+	PropertyCottage           PropertyType = "cottage"
+	PropertyGuesthouse        PropertyType = "guesthouse"
+	PropertyGuestSuite        PropertyType = "guest_suite"
+	PropertyPrivateRoom       PropertyType = "private_room"
+	PropertySharedRoom        PropertyType = "shared_room"
+	PropertyHotelRoom         PropertyType = "hotel_room"
+	PropertyHostel            PropertyType = "hostel"
+	PropertyOther             PropertyType = "other"
+)
+
+// Label returns a human-readable name, e.g. "Private room"
+func (p PropertyType) Label() string {
+	if p == "" {
+		return "Unknown"
+	}
+	s := strings.ReplaceAll(string(p), "_", " ")
+	return strings.ToUpper(s[:1]) + s[1:]
+}
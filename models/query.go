@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// ListingSortFields are the fields listings can be sorted by, named after
+// their alldata columns
+var ListingSortFields = []string{"price", "rating", "review_count", "value_score", "scraped_at", "title"}
+
+// ListingQuery filters, sorts and pages stored listings. Zero values leave a
+// filter out.
+type ListingQuery struct {
+	Location     string // normalized city, or the raw location when it has none; case-insensitive
+	PropertyType PropertyType
+	MinPrice     float64 // nightly, reporting currency
+	MaxPrice     float64
+	MinRating    float64
+	RunID        string // only listings seen in this run
+
+	SortBy     string // one of ListingSortFields; empty sorts by listing ID
+	Descending bool
+
+	Limit  int // 0 returns every match
+	Offset int
+}
+
+// RunSummary describes one stored scrape run
+type RunSummary struct {
+	RunID      string    `json:"run_id"`
+	StartedAt  time.Time `json:"started_at"` // first listing scraped
+	FinishedAt time.Time `json:"finished_at"`
+	Listings   int       `json:"listings"`
+}
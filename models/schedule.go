@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Scheduled run outcomes, besides the job statuses
+const (
+	ScheduleSkipped = "skipped" // the schedule's previous run was still going
+	ScheduleMissed  = "missed"  // the daemon was down or asleep at the planned time
+)
+
+// ScheduledRun is one firing of a schedule: the job it ran, or why it ran
+// none
+type ScheduledRun struct {
+	Schedule      string     `json:"schedule"`
+	PlannedAt     time.Time  `json:"planned_at"` // cron time, before jitter
+	Status        string     `json:"status"`     // a job status, skipped or missed
+	Reason        string     `json:"reason,omitempty"`
+	JobID         string     `json:"job_id,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty"`
+	CleanListings int        `json:"clean_listings,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// ScheduleStatus is a schedule as the daemon sees it
+type ScheduleStatus struct {
+	Name      string          `json:"name"`
+	Cron      string          `json:"cron"`
+	Timezone  string          `json:"timezone"`
+	Sections  []string        `json:"sections,omitempty"` // empty scrapes the configured sections
+	NextRunAt time.Time       `json:"next_run_at"`        // with jitter
+	Pending   bool            `json:"pending"`            // a run is waiting or running
+	LastRun   *ScheduledRun   `json:"last_run,omitempty"`
+	Runs      []*ScheduledRun `json:"runs,omitempty"` // latest first, only for a single schedule
+}
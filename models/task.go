@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Task kinds
+const (
+	TaskSection = "section" // a search section, paginated up to its limit
+	TaskTile    = "tile"    // a section's search restricted to one map tile
+	TaskDetail  = "detail"  // one listing's detail page, for its description
+)
+
+// Task statuses
+const (
+	TaskPending = "pending"
+	TaskRunning = "running" // leased by a worker
+	TaskDone    = "done"
+	TaskDead    = "dead" // failed every attempt; dead-lettered
+)
+
+// Queue run statuses
+const (
+	QueueRunOpen       = "open"       // tasks are still pending or running
+	QueueRunAssembling = "assembling" // a worker is processing the results
+	QueueRunDone       = "done"
+	QueueRunFailed     = "failed"
+)
+
+// Task is one unit of a distributed scrape run
+type Task struct {
+	ID          int64           `json:"id"`
+	RunID       string          `json:"run_id"`
+	Kind        string          `json:"kind"`
+	Name        string          `json:"name"` // section or tile name, or listing title
+	URL         string          `json:"url"`
+	Limit       int             `json:"limit,omitempty"` // listings wanted from a section or tile
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"` // raw listings, or a TaskDetailResult
+}
+
+// TaskDetailResult is the result of a detail task
+type TaskDetailResult struct {
+	Description string `json:"description"`
+	DetailHash  string `json:"detail_hash,omitempty"`
+}
+
+// QueueRun is a distributed run and how far its tasks are
+type QueueRun struct {
+	RunID      string     `json:"run_id"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Pending    int        `json:"pending"`
+	Running    int        `json:"running"`
+	Done       int        `json:"done"`
+	Dead       int        `json:"dead"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Snapshot is one listing as seen in one run, kept so runs can be compared
+type Snapshot struct {
+	RunID       string    `json:"run_id"`
+	ListingID   string    `json:"listing_id"`
+	Title       string    `json:"title"`
+	City        string    `json:"city"`
+	Price       float64   `json:"price"` // reporting currency
+	Rating      float64   `json:"rating"`
+	ReviewCount int       `json:"review_count"`
+	IsOutlier   bool      `json:"is_outlier"`
+	ScrapedAt   time.Time `json:"scraped_at"`
+}
+
+// TrendReport compares the latest run with earlier runs in a time window
+type TrendReport struct {
+	WindowDays    int              `json:"window_days"`
+	Runs          int              `json:"runs"` // runs found in the window
+	CurrentRunID  string           `json:"current_run_id"`
+	CurrentAt     time.Time        `json:"current_at"`
+	BaselineRunID string           `json:"baseline_run_id"` // run the medians and ratings are compared with
+	BaselineAt    time.Time        `json:"baseline_at"`
+	Locations     []*LocationTrend `json:"locations,omitempty"`
+	PriceChanges  []*PriceChange   `json:"price_changes,omitempty"` // largest relative change first
+}
+
+// LocationTrend is the change of one location between the baseline and the
+// current run
+type LocationTrend struct {
+	Location        string  `json:"location"`
+	MedianPrice     float64 `json:"median_price"`
+	BaselineMedian  float64 `json:"baseline_median"`
+	MedianChangePct float64 `json:"median_change_pct"`
+	AverageRating   float64 `json:"average_rating"`
+	BaselineRating  float64 `json:"baseline_rating"`
+	RatingDrift     float64 `json:"rating_drift"`
+	New             int     `json:"new"`         // listings never seen before in the window
+	Disappeared     int     `json:"disappeared"` // listings in the previous run but not in the current one
+}
+
+// PriceChange is a listing whose price differs from its previous snapshot
+type PriceChange struct {
+	ListingID string    `json:"listing_id"`
+	Title     string    `json:"title"`
+	Location  string    `json:"location"`
+	OldPrice  float64   `json:"old_price"`
+	NewPrice  float64   `json:"new_price"`
+	Change    float64   `json:"change"`
+	ChangePct float64   `json:"change_pct"`
+	Since     time.Time `json:"since"` // when the old price was seen
+}
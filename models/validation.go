@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Validation statuses and rule severities
+const (
+	ValidationAccepted = "accepted"
+	ValidationWarned   = "warned"
+	ValidationRejected = "rejected"
+
+	SeverityWarn   = "warn"
+	SeverityReject = "reject"
+)
+
+// ValidationIssue is one rule a listing failed
+type ValidationIssue struct {
+	Code     string `json:"code"` // stable reason code, e.g. "price_out_of_range"
+	Field    string `json:"field"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// QuarantinedListing is a rejected record kept for inspection instead of
+// being stored as clean data
+type QuarantinedListing struct {
+	RunID         string            `json:"run_id"`
+	ListingID     string            `json:"listing_id,omitempty"`
+	Raw           *RawListing       `json:"raw"`
+	Issues        []ValidationIssue `json:"issues"`
+	QuarantinedAt time.Time         `json:"quarantined_at"`
+}
@@ -0,0 +1,289 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"airbnb-scraper/config"
+	"airbnb-scraper/metrics"
+	"airbnb-scraper/models"
+	"airbnb-scraper/scraper/airbnb"
+	"airbnb-scraper/services"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+)
+
+// Stages of a run, in order
+const (
+	StageScraping  = "scraping"
+	StageCleaning  = "cleaning"
+	StageStoring   = "storing"
+	StageReporting = "reporting"
+)
+
+// ErrNoListings is returned when a scrape finds nothing to store
+var ErrNoListings = errors.New("no listings scraped")
+
+// Options adjusts a single run. Every field is optional.
+type Options struct {
+	RunID    string           // generated when empty
+	Progress airbnb.Progress  // scrape progress
+	Metrics  *metrics.Metrics // records the run; nil records nothing
+	OnStage  func(stage string)
+}
+
+// Result is what a completed run produced
+type Result struct {
+	RunID       string
+	RawListings int
+	Clean       *services.CleanResult
+	Report      *models.InsightReport
+}
+
+// Run is one full scrape: scrape → CSV + JSONL → clean → PostgreSQL →
+// insights → alerts. Cancelling ctx during the scrape stops it and nothing is
+// stored; cancelling it later stops the run with ctx.Err() before anything
+// goes to PostgreSQL. Once the clean listings are stored the run completes.
+// Failures of optional outputs (raw files, snapshots, quarantine, alerts) are
+// logged and do not fail the run.
+func Run(ctx context.Context, cfg *config.Config, logger *utils.Logger, opts Options) (result *Result, err error) {
+	start := time.Now()
+	defer func() { opts.runFinished(start, result, err) }()
+
+	runID := opts.RunID
+	if runID == "" {
+		runID = utils.NewRunID()
+	}
+	logger = logger.With("run_id", runID)
+	logger.Info("Airbnb Rental Scraping System — run %s", runID)
+
+	logger.Info("Properties per section: %d", cfg.PropertiesPerPage)
+	logger.Info("Concurrency: %d | Rate delay: %dms | Retries: %d",
+		cfg.MaxConcurrency, cfg.RateLimitDelay, cfg.MaxRetries)
+
+	p, err := newProcessor(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer p.close()
+
+	// =============== Scraping ===================================
+	opts.stage(StageScraping)
+	scraper := airbnb.NewAirbnbScraper(cfg, p.archive, logger)
+	if opts.Progress != nil {
+		scraper.SetProgress(opts.Progress)
+	}
+	scraper.SetMetrics(opts.Metrics)
+	rawListings, err := scraper.Scrape(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("scraping failed: %w", err)
+	}
+	return p.process(ctx, runID, rawListings, opts)
+}
+
+// Process runs everything after the scrape on listings scraped elsewhere,
+// such as a run assembled from queue tasks: CSV + JSONL → clean → PostgreSQL
+// → insights → alerts
+func Process(cfg *config.Config, logger *utils.Logger, runID string, rawListings []*models.RawListing, opts Options) (result *Result, err error) {
+	start := time.Now()
+	defer func() { opts.runFinished(start, result, err) }()
+
+	logger = logger.With("run_id", runID)
+	p, err := newProcessor(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer p.close()
+	return p.process(context.Background(), runID, rawListings, opts)
+}
+
+func (o Options) stage(name string) {
+	if o.OnStage != nil {
+		o.OnStage(name)
+	}
+}
+
+func (o Options) runFinished(start time.Time, result *Result, err error) {
+	clean := 0
+	if result != nil {
+		clean = len(result.Clean.Listings)
+	}
+	o.Metrics.RunFinished(time.Since(start), clean, err)
+}
+
+// processor holds what a run needs besides the scraper. It is set up before
+// scraping, so a bad rates table or alert rules fail before spending minutes
+// scraping.
+type processor struct {
+	cfg      *config.Config
+	logger   *utils.Logger
+	pgWriter *storage.PostgresWriter
+	cleaner  *services.DataCleaner
+	alerts   *services.AlertService // nil when alerts are off
+	archive  *storage.HTMLArchive   // nil when archiving is off
+}
+
+func newProcessor(cfg *config.Config, logger *utils.Logger) (*processor, error) {
+	// =================== PostgreSQL Setup ========================================
+	pgWriter, err := storage.NewPostgresWriter(cfg.DatabaseURL, logger)
+	if err != nil {
+		logger.Error("Make sure Docker is running: docker start my-postgres")
+		return nil, fmt.Errorf("cannot connect to PostgreSQL: %w", err)
+	}
+	p := &processor{cfg: cfg, logger: logger, pgWriter: pgWriter}
+
+	if err := pgWriter.CreateTable(); err != nil {
+		p.close()
+		return nil, err
+	}
+
+	p.cleaner, err = services.NewDataCleaner(cfg, logger)
+	if err != nil {
+		p.close()
+		return nil, fmt.Errorf("cannot set up data cleaner: %w", err)
+	}
+	if cfg.AlertRulesFile != "" {
+		p.alerts, err = services.LoadAlertService(cfg.AlertRulesFile, cfg.ReportingCurrency, logger)
+		if err != nil {
+			p.close()
+			return nil, fmt.Errorf("cannot set up alerts: %w", err)
+		}
+	}
+
+	// =============== Raw HTML Archive ===========================
+	p.archive = OpenArchive(cfg, logger)
+	if p.archive != nil && cfg.ArchiveRetentionDays > 0 {
+		retention := time.Duration(cfg.ArchiveRetentionDays) * 24 * time.Hour
+		if _, err := p.archive.Prune(retention); err != nil {
+			logger.Warn("Archive retention failed: %v", err)
+		}
+	}
+	return p, nil
+}
+
+func (p *processor) close() {
+	p.pgWriter.Close()
+}
+
+// process stores and analyses a run's raw listings. ctx is checked before the
+// raw files are written and again before anything goes to PostgreSQL.
+func (p *processor) process(ctx context.Context, runID string, rawListings []*models.RawListing, opts Options) (*Result, error) {
+	cfg, logger := p.cfg, p.logger
+	if len(rawListings) == 0 {
+		return nil, ErrNoListings
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, l := range rawListings {
+		l.RunID = runID
+	}
+
+	// ========= CSV + JSONL: store raw data ===========================
+	csvWriter := storage.NewCSVWriter(cfg.CSVFilePath, logger)
+	if err := csvWriter.WriteRawListings(rawListings); err != nil {
+		logger.Error("Failed to write CSV: %v", err)
+		// Non-fatal: continue to DB storage
+	}
+	jsonlWriter := storage.NewJSONLWriter(cfg.RawJSONLPath, logger)
+	if err := jsonlWriter.AppendRawListings(rawListings); err != nil {
+		logger.Error("Failed to append raw history: %v", err)
+		// Non-fatal: the CSV still has this run
+	}
+
+	// =========== Data Cleaning + Validation ======================
+	opts.stage(StageCleaning)
+	cleaned := p.cleaner.Clean(rawListings)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	StoreQuarantine(cfg, logger, p.pgWriter, cleaned.Quarantine)
+	recordCleaning(opts.Metrics, cleaned)
+
+	// Outliers and value scores are set before storing so they are persisted
+	insightSvc := services.NewInsightService(cfg, logger)
+	insightSvc.FlagOutliers(cleaned.Listings)
+	insightSvc.ScoreValue(cleaned.Listings)
+
+	// ========= PostgreSQL: store clean data ============
+	opts.stage(StageStoring)
+	stored, err := p.pgWriter.BatchInsert(cleaned.Listings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert into PostgreSQL: %w", err)
+	}
+	opts.Metrics.Stored("inserted", stored.Inserted)
+	opts.Metrics.Stored("updated", stored.Updated)
+	opts.Metrics.Stored("stale", stored.Stale)
+	opts.Metrics.Stored("skipped", stored.Skipped)
+	opts.Metrics.Stored("failed", stored.Failed)
+
+	if err := p.pgWriter.InsertSnapshots(cleaned.Listings); err != nil {
+		logger.Error("Failed to store listing snapshots: %v", err)
+		// Non-fatal: only trends miss this run
+	}
+
+	// ==== Insights ============================
+	opts.stage(StageReporting)
+	report := insightSvc.Generate(cleaned.Listings)
+	window := time.Duration(cfg.TrendWindowDays) * 24 * time.Hour
+	snapshots, err := p.pgWriter.LoadSnapshots(time.Now().Add(-window))
+	if err != nil {
+		logger.Warn("Trends and history-based alerts unavailable: %v", err)
+	} else {
+		report.Trends = insightSvc.Trends(snapshots)
+	}
+
+	// ==== Alerts ============================
+	if p.alerts != nil {
+		p.alerts.Notify(p.alerts.Evaluate(cleaned.Listings, services.NewAlertHistory(snapshots, runID)))
+	}
+
+	return &Result{RunID: runID, RawListings: len(rawListings), Clean: cleaned, Report: report}, nil
+}
+
+// recordCleaning records validation statuses and why the cleaner dropped raw
+// listings: as duplicates, or by the rules that rejected them
+func recordCleaning(m *metrics.Metrics, cleaned *services.CleanResult) {
+	m.Cleaned(models.ValidationAccepted, cleaned.Stats.Accepted)
+	m.Cleaned(models.ValidationWarned, cleaned.Stats.Warned)
+	m.Cleaned(models.ValidationRejected, cleaned.Stats.Rejected)
+	m.Dropped("duplicate", cleaned.Stats.Duplicates)
+	for _, q := range cleaned.Quarantine {
+		for _, is := range q.Issues {
+			if is.Severity == models.SeverityReject {
+				m.Dropped(is.Code, 1)
+			}
+		}
+	}
+}
+
+// OpenArchive opens the raw HTML archive, or returns nil when it is disabled
+// or unavailable. A broken archive never stops a scrape.
+func OpenArchive(cfg *config.Config, logger *utils.Logger) *storage.HTMLArchive {
+	if !cfg.ArchiveEnabled {
+		return nil
+	}
+	archive, err := storage.NewHTMLArchive(cfg.ArchiveDir, logger)
+	if err != nil {
+		logger.Warn("Raw HTML archive disabled: %v", err)
+		return nil
+	}
+	return archive
+}
+
+// StoreQuarantine writes rejected records to the quarantine file and table.
+// Both are best-effort: losing a quarantine row never fails the run.
+func StoreQuarantine(cfg *config.Config, logger *utils.Logger, pgWriter *storage.PostgresWriter, records []*models.QuarantinedListing) {
+	if len(records) == 0 {
+		return
+	}
+	if err := storage.NewJSONLWriter(cfg.QuarantinePath, logger).AppendQuarantined(records); err != nil {
+		logger.Error("Failed to write quarantine file: %v", err)
+	}
+	if err := pgWriter.InsertQuarantined(records); err != nil {
+		logger.Error("Failed to store quarantine in PostgreSQL: %v", err)
+	}
+}
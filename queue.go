@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"airbnb-scraper/config"
+	"airbnb-scraper/models"
+	"airbnb-scraper/queue"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+)
+
+// runQueue manages distributed runs: enqueue opens a run, status shows how
+// far runs are and their dead-lettered tasks, retry requeues those tasks
+func runQueue(cfg *config.Config, logger *utils.Logger, args []string) {
+	sub := ""
+	if len(args) > 0 {
+		sub, args = args[0], args[1:]
+	}
+
+	switch sub {
+	case "enqueue":
+		fs := flag.NewFlagSet("queue enqueue", flag.ExitOnError)
+		runID := fs.String("run", "", "run ID (default: generated)")
+		tiles := fs.Int("tiles", 0, "split each section into an N × N grid of map tiles")
+		tileKM := fs.Float64("tile-km", 2, "width and height of one map tile, in km")
+		_ = fs.Parse(args)
+
+		q := openTaskQueue(cfg, logger)
+		defer q.Close()
+		id, tasks, err := queue.Enqueue(context.Background(), cfg, q, logger, queue.PlanOptions{RunID: *runID, Tiles: *tiles, TileKM: *tileKM})
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+		logger.Info("Queued run %s with %d tasks; start workers with: worker", id, len(tasks))
+		fmt.Println(id)
+
+	case "status":
+		fs := flag.NewFlagSet("queue status", flag.ExitOnError)
+		runID := fs.String("run", "", "show one run and its dead-lettered tasks (default: the latest runs)")
+		limit := fs.Int("limit", 10, "number of runs to show")
+		_ = fs.Parse(args)
+
+		q := openTaskQueue(cfg, logger)
+		defer q.Close()
+		runs, err := q.Runs(*runID, *limit)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+		if *runID != "" && len(runs) == 0 {
+			logger.Error("Run %s not found in the queue", *runID)
+			os.Exit(1)
+		}
+		fmt.Printf("%-24s %-11s %8s %8s %8s %8s\n", "RUN", "STATUS", "PENDING", "RUNNING", "DONE", "DEAD")
+		for _, r := range runs {
+			fmt.Printf("%-24s %-11s %8d %8d %8d %8d\n", r.RunID, r.Status, r.Pending, r.Running, r.Done, r.Dead)
+			if r.Error != "" {
+				fmt.Printf("  error: %s\n", r.Error)
+			}
+		}
+		if *runID == "" || runs[0].Dead == 0 {
+			return
+		}
+
+		tasks, err := q.RunTasks(*runID)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+		fmt.Println("\nDead-lettered tasks (requeue with: queue retry -run " + *runID + ")")
+		for _, t := range tasks {
+			if t.Status == models.TaskDead {
+				fmt.Printf("  #%d %s '%s' after %d attempts: %s\n    %s\n", t.ID, t.Kind, t.Name, t.Attempts, t.LastError, t.URL)
+			}
+		}
+
+	case "retry":
+		fs := flag.NewFlagSet("queue retry", flag.ExitOnError)
+		runID := fs.String("run", "", "run whose dead-lettered tasks to requeue")
+		_ = fs.Parse(args)
+		if *runID == "" {
+			logger.Error("queue retry needs -run")
+			os.Exit(2)
+		}
+
+		q := openTaskQueue(cfg, logger)
+		defer q.Close()
+		n, err := q.RetryDead(*runID)
+		if err != nil {
+			logger.Error("%v", err)
+			os.Exit(1)
+		}
+		logger.Info("Requeued %d dead-lettered tasks of run %s", n, *runID)
+
+	default:
+		logger.Error("Unknown queue command %q (available: enqueue, status, retry)", sub)
+		os.Exit(2)
+	}
+}
+
+// openTaskQueue connects to the task queue tables, creating them if needed
+func openTaskQueue(cfg *config.Config, logger *utils.Logger) *storage.TaskQueue {
+	q, err := storage.NewTaskQueue(cfg.DatabaseURL, logger)
+	if err != nil {
+		logger.Error("Cannot connect to the task queue: %v", err)
+		logger.Error("Make sure Docker is running: docker start my-postgres")
+		os.Exit(1)
+	}
+	if err := q.CreateTables(); err != nil {
+		q.Close()
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+	return q
+}
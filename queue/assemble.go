@@ -0,0 +1,62 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"airbnb-scraper/models"
+	"airbnb-scraper/utils"
+)
+
+// assemble merges the results of a run's tasks into the raw listings a
+// single-machine scrape would have produced. Section and tile results are
+// taken in task order, the first copy of a listing winning when tiles
+// overlap; detail results then fill in descriptions. Dead-lettered tasks
+// contribute nothing and are counted.
+func assemble(tasks []*models.Task) ([]*models.RawListing, int, error) {
+	var listings []*models.RawListing
+	byKey := make(map[string]*models.RawListing)
+	dead := 0
+
+	for _, t := range tasks {
+		if t.Status == models.TaskDead {
+			dead++
+			continue
+		}
+		if t.Status != models.TaskDone || len(t.Result) == 0 || t.Kind == models.TaskDetail {
+			continue
+		}
+		var found []*models.RawListing
+		if err := json.Unmarshal(t.Result, &found); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode result of task %d: %w", t.ID, err)
+		}
+		for _, l := range found {
+			key := utils.ListingKey(l.URL)
+			if key != "" {
+				if _, seen := byKey[key]; seen {
+					continue
+				}
+				byKey[key] = l
+			}
+			listings = append(listings, l)
+		}
+	}
+
+	for _, t := range tasks {
+		if t.Kind != models.TaskDetail || t.Status != models.TaskDone || len(t.Result) == 0 {
+			continue
+		}
+		l, ok := byKey[utils.ListingKey(t.URL)]
+		if !ok {
+			continue
+		}
+		var detail models.TaskDetailResult
+		if err := json.Unmarshal(t.Result, &detail); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode result of task %d: %w", t.ID, err)
+		}
+		if l.Description == "" {
+			l.Description, l.DetailHash = detail.Description, detail.DetailHash
+		}
+	}
+	return listings, dead, nil
+}
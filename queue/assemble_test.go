@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"airbnb-scraper/models"
+)
+
+func TestAssemble(t *testing.T) {
+	room := func(id, title, description string) *models.RawListing {
+		return &models.RawListing{Title: title, URL: "https://www.airbnb.com/rooms/" + id + "?check_in=2026-11-01", Description: description}
+	}
+	listings := func(ls ...*models.RawListing) json.RawMessage {
+		b, _ := json.Marshal(ls)
+		return b
+	}
+	detail := func(description, hash string) json.RawMessage {
+		b, _ := json.Marshal(models.TaskDetailResult{Description: description, DetailHash: hash})
+		return b
+	}
+	task := func(kind, status, url string, result json.RawMessage) *models.Task {
+		return &models.Task{Kind: kind, Status: status, URL: url, Result: result}
+	}
+
+	tests := []struct {
+		name  string
+		tasks []*models.Task
+		want  []string // title|description|detail hash of each listing
+		dead  int
+		err   string
+	}{
+		{
+			name: "first copy wins across overlapping tiles",
+			tasks: []*models.Task{
+				task(models.TaskTile, models.TaskDone, "", listings(room("1", "Loft A", ""), room("2", "Villa", ""))),
+				task(models.TaskTile, models.TaskDone, "", listings(room("1", "Loft B", ""), room("3", "Hut", ""))),
+			},
+			want: []string{"Loft A||", "Villa||", "Hut||"},
+		},
+		{
+			name: "detail fills an empty description only",
+			tasks: []*models.Task{
+				task(models.TaskDetail, models.TaskDone, "https://www.airbnb.com/rooms/1", detail("Sunny loft", "h1")),
+				task(models.TaskSection, models.TaskDone, "", listings(room("1", "Loft", ""), room("2", "Villa", "From the card"))),
+				task(models.TaskDetail, models.TaskDone, "https://www.airbnb.com/rooms/2", detail("From the page", "h2")),
+				task(models.TaskDetail, models.TaskDone, "https://www.airbnb.com/rooms/9", detail("Unknown room", "h9")),
+			},
+			want: []string{"Loft|Sunny loft|h1", "Villa|From the card|"},
+		},
+		{
+			name: "dead and unfinished tasks contribute nothing",
+			tasks: []*models.Task{
+				task(models.TaskSection, models.TaskDead, "", nil),
+				task(models.TaskTile, models.TaskPending, "", listings(room("4", "Cabin", ""))),
+				task(models.TaskTile, models.TaskDone, "", listings(room("5", "Barn", ""))),
+				task(models.TaskDetail, models.TaskDead, "https://www.airbnb.com/rooms/5", nil),
+			},
+			want: []string{"Barn||"},
+			dead: 2,
+		},
+		{
+			name: "bad listings result",
+			tasks: []*models.Task{
+				task(models.TaskSection, models.TaskDone, "", json.RawMessage(`{"title":`)),
+			},
+			err: "failed to decode result of task",
+		},
+		{
+			name: "bad detail result",
+			tasks: []*models.Task{
+				task(models.TaskSection, models.TaskDone, "", listings(room("1", "Loft", ""))),
+				task(models.TaskDetail, models.TaskDone, "https://www.airbnb.com/rooms/1", json.RawMessage(`[1]`)),
+			},
+			err: "failed to decode result of task",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dead, err := assemble(tt.tasks)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("assemble() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("assemble() error = %v", err)
+			}
+			if dead != tt.dead {
+				t.Errorf("dead = %d, want %d", dead, tt.dead)
+			}
+			var summary []string
+			for _, l := range got {
+				summary = append(summary, l.Title+"|"+l.Description+"|"+l.DetailHash)
+			}
+			if strings.Join(summary, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("listings =\n%s\nwant\n%s", strings.Join(summary, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
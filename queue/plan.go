@@ -0,0 +1,113 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+
+	"airbnb-scraper/config"
+	"airbnb-scraper/models"
+	"airbnb-scraper/scraper/airbnb"
+	"airbnb-scraper/services"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+)
+
+// kmPerDegree is the length of one degree of latitude
+const kmPerDegree = 111.32
+
+// PlanOptions shape the tasks of a new run
+type PlanOptions struct {
+	RunID  string  // generated when empty
+	Tiles  int     // split each section into a Tiles × Tiles grid of map tiles; 0 or 1 keeps whole sections
+	TileKM float64 // width and height of one tile
+}
+
+// Enqueue plans the sections of a run the way a single-machine scrape would
+// and opens the run in the queue: one task per section, or per map tile with
+// PlanOptions.Tiles. Each section or tile collects up to
+// PROPERTIES_PER_SECTION listings.
+func Enqueue(ctx context.Context, cfg *config.Config, q *storage.TaskQueue, logger *utils.Logger, opts PlanOptions) (string, []*models.Task, error) {
+	runID := opts.RunID
+	if runID == "" {
+		runID = utils.NewRunID()
+	}
+
+	// The browser only starts if sections have to be discovered
+	scraper := airbnb.NewAirbnbScraper(cfg, nil, logger)
+	browser, cancel := scraper.NewBrowserContext(ctx)
+	defer cancel()
+	sections := scraper.PlanSections(browser)
+
+	var gazetteer *services.Gazetteer
+	if opts.Tiles > 1 {
+		if opts.TileKM <= 0 {
+			return "", nil, fmt.Errorf("tile size must be positive, got %.2f km", opts.TileKM)
+		}
+		var err error
+		if gazetteer, err = services.LoadGazetteer(cfg.GazetteerFile); err != nil {
+			return "", nil, err
+		}
+	}
+
+	var tasks []*models.Task
+	for _, sec := range sections {
+		task := &models.Task{RunID: runID, Kind: models.TaskSection, Name: sec.Name, URL: sec.URL,
+			Limit: cfg.PropertiesPerPage, MaxAttempts: cfg.QueueMaxAttempts}
+		if gazetteer == nil {
+			tasks = append(tasks, task)
+			continue
+		}
+		place, ok := gazetteer.Lookup(sec.Name)
+		if !ok || (place.Latitude == 0 && place.Longitude == 0) {
+			logger.Warn("No coordinates for section '%s' in the gazetteer; queued whole", sec.Name)
+			tasks = append(tasks, task)
+			continue
+		}
+		for _, tileURL := range tileURLs(sec.URL, place.Latitude, place.Longitude, opts.Tiles, opts.TileKM) {
+			tile := *task
+			tile.Kind, tile.URL = models.TaskTile, tileURL
+			tasks = append(tasks, &tile)
+		}
+	}
+	if len(tasks) == 0 {
+		return "", nil, fmt.Errorf("no sections to queue")
+	}
+
+	if err := q.CreateRun(runID, tasks); err != nil {
+		return "", nil, err
+	}
+	return runID, tasks, nil
+}
+
+// tileURLs splits the area around a place into an n × n grid of tiles km
+// wide and returns the section's search restricted to each tile
+func tileURLs(searchURL string, lat, lon float64, n int, km float64) []string {
+	u, err := url.Parse(searchURL)
+	if err != nil {
+		return []string{searchURL}
+	}
+	dLat := km / kmPerDegree
+	dLon := km / (kmPerDegree * math.Cos(lat*math.Pi/180))
+	south := lat - dLat*float64(n)/2
+	west := lon - dLon*float64(n)/2
+	coord := func(v float64) string { return strconv.FormatFloat(v, 'f', 5, 64) }
+
+	var urls []string
+	for row := 0; row < n; row++ {
+		for col := 0; col < n; col++ {
+			q := u.Query()
+			q.Set("search_by_map", "true")
+			q.Set("sw_lat", coord(south+dLat*float64(row)))
+			q.Set("sw_lng", coord(west+dLon*float64(col)))
+			q.Set("ne_lat", coord(south+dLat*float64(row+1)))
+			q.Set("ne_lng", coord(west+dLon*float64(col+1)))
+			tile := *u
+			tile.RawQuery = q.Encode()
+			urls = append(urls, tile.String())
+		}
+	}
+	return urls
+}
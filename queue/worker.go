@@ -0,0 +1,328 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"airbnb-scraper/config"
+	"airbnb-scraper/models"
+	"airbnb-scraper/pipeline"
+	"airbnb-scraper/scraper/airbnb"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+)
+
+// Worker claims tasks from the queue and runs them in its own browser. Any
+// number of workers, on any number of machines, can share a run. The worker
+// that finds a run with no task left to do assembles the results and
+// processes the run like a single-machine scrape.
+type Worker struct {
+	cfg     *config.Config
+	queue   *storage.TaskQueue
+	logger  *utils.Logger
+	id      string
+	archive *storage.HTMLArchive
+	limiter *utils.RateLimiter // between tasks; the scraper waits between pages
+}
+
+// NewWorker creates a Worker. An empty id becomes host-pid. The lease and
+// poll intervals must be positive.
+func NewWorker(cfg *config.Config, q *storage.TaskQueue, logger *utils.Logger, id string) (*Worker, error) {
+	if cfg.QueueLeaseSeconds <= 0 {
+		return nil, fmt.Errorf("QUEUE_LEASE_SECONDS must be positive, got %d", cfg.QueueLeaseSeconds)
+	}
+	if cfg.QueuePollSeconds <= 0 {
+		return nil, fmt.Errorf("QUEUE_POLL_SECONDS must be positive, got %d", cfg.QueuePollSeconds)
+	}
+	if id == "" {
+		host, _ := os.Hostname()
+		id = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	logger = logger.With("worker", id)
+	return &Worker{
+		cfg:     cfg,
+		queue:   q,
+		logger:  logger,
+		id:      id,
+		archive: pipeline.OpenArchive(cfg, logger),
+		limiter: utils.NewRateLimiter(cfg.RateLimitDelay),
+	}, nil
+}
+
+// maxRetryBackoff caps the retry delay, which doubles with each attempt
+const maxRetryBackoff = time.Hour
+
+// retryBackoff returns the delay before retrying after the given attempt:
+// delaySeconds, doubled for each attempt after the first, at most
+// maxRetryBackoff
+func retryBackoff(delaySeconds, attempt int) time.Duration {
+	if delaySeconds >= int(maxRetryBackoff/time.Second) {
+		return maxRetryBackoff
+	}
+	backoff := time.Duration(delaySeconds) * time.Second
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
+
+// ID returns the name the worker leases tasks under
+func (w *Worker) ID() string {
+	return w.id
+}
+
+// Run works until ctx is done, or with exitWhenIdle until no task can be
+// claimed. A task in progress when ctx is done is handed back to the queue.
+func (w *Worker) Run(ctx context.Context, exitWhenIdle bool) error {
+	browser, cancel := airbnb.NewAirbnbScraper(w.cfg, w.archive, w.logger).NewBrowserContext(ctx)
+	defer cancel()
+
+	poll := time.Duration(w.cfg.QueuePollSeconds) * time.Second
+	lease := time.Duration(w.cfg.QueueLeaseSeconds) * time.Second
+	for ctx.Err() == nil {
+		w.assembleFinishedRuns()
+
+		task, err := w.queue.Claim(w.id, lease)
+		if err != nil {
+			w.logger.Error("%v", err)
+		}
+		if task != nil {
+			w.runTask(ctx, browser, task)
+			continue
+		}
+		if err == nil && exitWhenIdle {
+			w.logger.Info("No task left, exiting")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(poll):
+		}
+	}
+	return ctx.Err()
+}
+
+// runTask runs one claimed task, renewing its lease meanwhile, and reports
+// the outcome to the queue
+func (w *Worker) runTask(ctx, browser context.Context, task *models.Task) {
+	log := w.logger.With("run_id", task.RunID, "task_id", task.ID, "attempt", task.Attempts)
+	log.Info("Task %d: %s '%s' (attempt %d/%d)", task.ID, task.Kind, task.Name, task.Attempts, task.MaxAttempts)
+
+	taskCtx, cancel := context.WithCancel(browser)
+	defer cancel()
+	lost := make(chan struct{})
+	go w.renewLease(taskCtx, cancel, task, lost)
+
+	w.limiter.Wait()
+	result, next, err := w.execute(taskCtx, log, task)
+	leaseLost := false
+	select {
+	case <-lost:
+		leaseLost = true
+	default:
+	}
+	cancel()
+
+	switch {
+	case leaseLost:
+		log.Warn("Lost the lease of task %d; another worker has it", task.ID)
+	case ctx.Err() != nil:
+		if err := w.queue.Release(task, w.id); err != nil {
+			log.Warn("Could not release task %d: %v", task.ID, err)
+		}
+	case err != nil:
+		backoff := retryBackoff(w.cfg.QueueRetryDelaySeconds, task.Attempts)
+		dead, ferr := w.queue.Fail(task, w.id, err, backoff)
+		switch {
+		case ferr != nil:
+			log.Error("Task %d failed (%v) and could not be recorded: %v", task.ID, err, ferr)
+		case dead:
+			log.Error("Task %d dead-lettered after %d attempts: %v", task.ID, task.Attempts, err)
+		default:
+			log.Warn("Task %d failed, retrying in %s: %v", task.ID, backoff, err)
+		}
+	default:
+		if err := w.queue.Complete(task, w.id, result, next); err != nil {
+			log.Error("Could not complete task %d: %v", task.ID, err)
+			return
+		}
+		log.Info("Task %d done (%d follow-up tasks)", task.ID, len(next))
+	}
+}
+
+// renewLease extends the task's lease every third of its duration until ctx
+// is done. When the lease is lost it closes lost and cancels the task.
+func (w *Worker) renewLease(ctx context.Context, cancel context.CancelFunc, task *models.Task, lost chan<- struct{}) {
+	lease := time.Duration(w.cfg.QueueLeaseSeconds) * time.Second
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := w.queue.ExtendLease(task, w.id, lease)
+			if errors.Is(err, storage.ErrLeaseLost) {
+				close(lost)
+				cancel()
+				return
+			}
+			if err != nil {
+				w.logger.With("run_id", task.RunID, "task_id", task.ID).Warn("Could not renew the lease of task %d: %v", task.ID, err)
+			}
+		}
+	}
+}
+
+// execute scrapes a task and returns its result and follow-up tasks. A
+// section or tile yields raw listings, and a detail task for each listing
+// whose card had no description.
+func (w *Worker) execute(ctx context.Context, log *utils.Logger, task *models.Task) ([]byte, []*models.Task, error) {
+	cfg := *w.cfg
+	if task.Limit > 0 {
+		cfg.PropertiesPerPage = task.Limit
+	}
+	scraper := airbnb.NewAirbnbScraper(&cfg, w.archive, log)
+
+	switch task.Kind {
+	case models.TaskSection, models.TaskTile:
+		scraper.SetDetailPages(false)
+		listings, err := scraper.ScrapeSection(ctx, airbnb.LocationSection{Name: task.Name, URL: task.URL})
+		if err == nil {
+			err = ctx.Err() // a cancelled section returns what it had
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var next []*models.Task
+		for _, l := range listings {
+			if l.Description != "" || l.URL == "" {
+				continue
+			}
+			detailURL := l.URL
+			if _, canonical := utils.CanonicalizeListingURL(l.URL); canonical != "" {
+				detailURL = canonical // one detail task per listing, whichever section found it
+			}
+			next = append(next, &models.Task{RunID: task.RunID, Kind: models.TaskDetail, Name: l.Title,
+				URL: detailURL, MaxAttempts: task.MaxAttempts})
+		}
+		result, err := json.Marshal(listings)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode listings: %w", err)
+		}
+		return result, next, nil
+
+	case models.TaskDetail:
+		l := &models.RawListing{Title: task.Name, URL: task.URL}
+		if err := scraper.FetchDetail(ctx, l); err != nil {
+			return nil, nil, err
+		}
+		result, err := json.Marshal(models.TaskDetailResult{Description: l.Description, DetailHash: l.DetailHash})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode detail: %w", err)
+		}
+		return result, nil, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown task kind %q", task.Kind)
+	}
+}
+
+// assembleFinishedRuns processes every run with no task left to do. A run
+// whose assembly fails, say because the database was briefly unreachable, is
+// handed back to be assembled again after a backoff, up to QueueMaxAttempts
+// times.
+func (w *Worker) assembleFinishedRuns() {
+	lease := time.Duration(w.cfg.QueueLeaseSeconds) * time.Second
+	for {
+		runID, attempt, err := w.queue.ClaimFinishedRun(w.id, lease)
+		if err != nil {
+			w.logger.Error("%v", err)
+			return
+		}
+		if runID == "" {
+			return
+		}
+
+		log := w.logger.With("run_id", runID, "attempt", attempt)
+		ctx, cancel := context.WithCancel(context.Background())
+		go w.renewRunLease(ctx, log, runID)
+		err = w.assembleRun(log, runID)
+		cancel()
+
+		if err == nil {
+			if ferr := w.queue.FinishRun(runID, w.id); ferr != nil {
+				log.Error("Could not finish run %s: %v", runID, ferr)
+			}
+			continue
+		}
+
+		maxAttempts := w.cfg.QueueMaxAttempts
+		if errors.Is(err, pipeline.ErrNoListings) {
+			maxAttempts = 0 // assembling again would find nothing either
+		}
+		backoff := retryBackoff(w.cfg.QueueRetryDelaySeconds, attempt)
+		failed, ferr := w.queue.FailRun(runID, w.id, err, maxAttempts, backoff)
+		switch {
+		case ferr != nil:
+			log.Error("Run %s failed (%v) and could not be recorded: %v", runID, err, ferr)
+		case failed:
+			log.Error("Run %s failed after %d assembly attempts: %v", runID, attempt, err)
+		default:
+			log.Warn("Assembling run %s failed, retrying in %s: %v", runID, backoff, err)
+		}
+	}
+}
+
+// renewRunLease extends the lease of a run being assembled every third of its
+// duration until ctx is done, so a long assembly is not taken over
+func (w *Worker) renewRunLease(ctx context.Context, log *utils.Logger, runID string) {
+	lease := time.Duration(w.cfg.QueueLeaseSeconds) * time.Second
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := w.queue.ExtendRunLease(runID, w.id, lease)
+			if errors.Is(err, storage.ErrLeaseLost) {
+				log.Warn("Lost the lease of run %s; another worker may assemble it too", runID)
+				return
+			}
+			if err != nil {
+				log.Warn("Could not renew the lease of run %s: %v", runID, err)
+			}
+		}
+	}
+}
+
+// assembleRun merges a run's task results and processes them: clean, store,
+// insights and alerts
+func (w *Worker) assembleRun(log *utils.Logger, runID string) error {
+	tasks, err := w.queue.RunTasks(runID)
+	if err != nil {
+		return err
+	}
+	rawListings, dead, err := assemble(tasks)
+	if err != nil {
+		return err
+	}
+	log.Info("Assembling run %s: %d raw listings from %d tasks (%d dead-lettered)",
+		runID, len(rawListings), len(tasks), dead)
+
+	result, err := pipeline.Process(w.cfg, w.logger, runID, rawListings, pipeline.Options{})
+	if err != nil {
+		return err
+	}
+	log.Info("Run %s stored %d clean listings", runID, len(result.Clean.Listings))
+	return nil
+}
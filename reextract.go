@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"airbnb-scraper/config"
+	"airbnb-scraper/scraper/airbnb"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+)
+
+// runReextract re-runs the current extraction code over archived pages and
+// writes the resulting raw listings to CSV, without touching the live site
+func runReextract(cfg *config.Config, logger *utils.Logger, args []string) {
+	fs := flag.NewFlagSet("reextract", flag.ExitOnError)
+	since := fs.String("since", "", "only pages fetched on or after this date (YYYY-MM-DD)")
+	until := fs.String("until", "", "only pages fetched before this date (YYYY-MM-DD)")
+	section := fs.String("section", "", "only search pages from this section")
+	out := fs.String("out", "output/reextracted_listings.csv", "CSV file to write re-extracted raw listings to")
+	_ = fs.Parse(args)
+
+	from, to, err := parseDateRange(*since, *until)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(2)
+	}
+
+	archive, err := storage.NewHTMLArchive(cfg.ArchiveDir, logger)
+	if err != nil {
+		logger.Error("Cannot open archive: %v", err)
+		os.Exit(1)
+	}
+	entries, err := archive.Entries()
+	if err != nil {
+		logger.Error("Cannot read archive index: %v", err)
+		os.Exit(1)
+	}
+
+	var selected []storage.ArchiveEntry
+	for _, e := range entries {
+		if !from.IsZero() && e.FetchedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !e.FetchedAt.Before(to) {
+			continue
+		}
+		if *section != "" && e.Kind == storage.ArchiveKindSearch && e.Section != *section {
+			continue
+		}
+		selected = append(selected, e)
+	}
+	if len(selected) == 0 {
+		logger.Warn("No archived pages match the given filters")
+		return
+	}
+	logger.Info("Re-extracting from %d archived pages...", len(selected))
+
+	scraper := airbnb.NewAirbnbScraper(cfg, nil, logger)
+	rawListings, err := scraper.ReextractArchived(archive, selected)
+	if err != nil {
+		logger.Error("Re-extraction failed: %v", err)
+		os.Exit(1)
+	}
+
+	csvWriter := storage.NewCSVWriter(*out, logger)
+	if err := csvWriter.WriteRawListings(rawListings); err != nil {
+		logger.Error("Failed to write CSV: %v", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf(" Re-extracted %d listings → %s\n", len(rawListings), *out)
+}
+
+// parseDateRange parses optional YYYY-MM-DD bounds; zero times mean unbounded
+func parseDateRange(since, until string) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+	if since != "" {
+		if from, err = time.ParseInLocation("2006-01-02", since, time.Local); err != nil {
+			return from, to, fmt.Errorf("invalid -since date %q: %w", since, err)
+		}
+	}
+	if until != "" {
+		if to, err = time.ParseInLocation("2006-01-02", until, time.Local); err != nil {
+			return from, to, fmt.Errorf("invalid -until date %q: %w", until, err)
+		}
+	}
+	return from, to, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"airbnb-scraper/config"
+	"airbnb-scraper/models"
+	"airbnb-scraper/pipeline"
+	"airbnb-scraper/scraper/airbnb"
+	"airbnb-scraper/services"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+)
+
+// runReprocess re-cleans historical raw listings with the current DataCleaner
+// and upserts the corrected rows, so cleaner fixes apply to past runs too
+func runReprocess(cfg *config.Config, logger *utils.Logger, args []string) {
+	fs := flag.NewFlagSet("reprocess", flag.ExitOnError)
+	from := fs.String("from", cfg.RawJSONLPath, "raw CSV or JSONL file to read")
+	fromArchive := fs.Bool("archive", false, "re-extract raw listings from the HTML archive instead of -from")
+	runID := fs.String("run", "", "only listings from this run ID")
+	since := fs.String("since", "", "only listings scraped on or after this date (YYYY-MM-DD)")
+	until := fs.String("until", "", "only listings scraped before this date (YYYY-MM-DD)")
+	_ = fs.Parse(args)
+
+	start, end, err := parseDateRange(*since, *until)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(2)
+	}
+	if *fromArchive && *runID != "" {
+		logger.Error("-run cannot be used with -archive: archived pages are not tagged with run IDs")
+		os.Exit(2)
+	}
+
+	// =========== Load raw listings ======================
+	var rawListings []*models.RawListing
+	if *fromArchive {
+		rawListings, err = loadArchivedRaw(cfg, logger)
+	} else {
+		rawListings, err = storage.ReadRawListings(*from)
+	}
+	if err != nil {
+		logger.Error("Cannot load raw listings: %v", err)
+		os.Exit(1)
+	}
+
+	var selected []*models.RawListing
+	for _, l := range rawListings {
+		if *runID != "" && l.RunID != *runID {
+			continue
+		}
+		if !start.IsZero() && l.ScrapedAt.Before(start) {
+			continue
+		}
+		if !end.IsZero() && !l.ScrapedAt.Before(end) {
+			continue
+		}
+		selected = append(selected, l)
+	}
+	if len(selected) == 0 {
+		logger.Warn("No raw listings match the given filters")
+		return
+	}
+	logger.Info("Reprocessing %d of %d raw listings...", len(selected), len(rawListings))
+
+	// =========== Clean + upsert ======================
+	cleaner, err := services.NewDataCleaner(cfg, logger)
+	if err != nil {
+		logger.Error("Cannot set up data cleaner: %v", err)
+		os.Exit(1)
+	}
+	insightSvc := services.NewInsightService(cfg, logger)
+
+	pgWriter, err := storage.NewPostgresWriter(cfg.DatabaseURL, logger)
+	if err != nil {
+		logger.Error("Cannot connect to PostgreSQL: %v", err)
+		os.Exit(1)
+	}
+	defer pgWriter.Close()
+
+	if err := pgWriter.CreateTable(); err != nil {
+		logger.Error("Failed to create DB table: %v", err)
+		os.Exit(1)
+	}
+
+	// Each run is cleaned on its own, as it was when scraped: deduplicating
+	// across runs would keep only the oldest copy of a recurring listing.
+	// Runs go oldest first so the latest values end up in alldata.
+	// Archived pages carry no run ID and form a single group.
+	runs := make(map[string][]*models.RawListing)
+	for _, l := range selected {
+		runs[l.RunID] = append(runs[l.RunID], l)
+	}
+	order := latestRuns(runs)
+	total := 0
+	result := &storage.UpsertResult{FieldChanges: make(map[string]int)}
+	stats := services.ValidationStats{RuleHits: make(map[string]int)}
+	for i := len(order) - 1; i >= 0; i-- {
+		runLogger := logger.With("run_id", order[i])
+		cleaned := cleaner.Clean(runs[order[i]])
+		insightSvc.FlagOutliers(cleaned.Listings)
+		insightSvc.ScoreValue(cleaned.Listings)
+
+		pipeline.StoreQuarantine(cfg, runLogger, pgWriter, cleaned.Quarantine)
+
+		runResult, err := pgWriter.Upsert(cleaned.Listings)
+		if err != nil {
+			runLogger.Error("Failed to upsert listings: %v", err)
+			os.Exit(1)
+		}
+		if err := pgWriter.InsertSnapshots(cleaned.Listings); err != nil {
+			runLogger.Error("Failed to store listing snapshots: %v", err)
+		}
+		total += len(cleaned.Listings)
+		result.Add(runResult)
+		stats.Add(cleaned.Stats)
+	}
+
+	printReprocessSummary(total, len(runs), result)
+	services.PrintValidationSummary(stats)
+}
+
+// loadArchivedRaw re-extracts every archived page with the current extraction code
+func loadArchivedRaw(cfg *config.Config, logger *utils.Logger) ([]*models.RawListing, error) {
+	archive, err := storage.NewHTMLArchive(cfg.ArchiveDir, logger)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := archive.Entries()
+	if err != nil {
+		return nil, err
+	}
+	scraper := airbnb.NewAirbnbScraper(cfg, nil, logger)
+	return scraper.ReextractArchived(archive, entries)
+}
+
+func printReprocessSummary(total, runs int, result *storage.UpsertResult) {
+	fmt.Printf("\n Reprocessed %d clean listings from %d runs\n", total, runs)
+	fmt.Printf("  Inserted  : %d\n", result.Inserted)
+	fmt.Printf("  Updated   : %d\n", result.Updated)
+	fmt.Printf("  Unchanged : %d\n", result.Unchanged)
+	fmt.Printf("  Stale     : %d (stored row is newer)\n", result.Stale)
+
+	if len(result.FieldChanges) == 0 {
+		return
+	}
+	fields := make([]string, 0, len(result.FieldChanges))
+	for f := range result.FieldChanges {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	fmt.Println("  Values changed:")
+	for _, f := range fields {
+		fmt.Printf("    %-12s %d\n", f+":", result.FieldChanges[f])
+	}
+}
@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * foo *",
+		"@often",
+	} {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := ParseCron(expr); err == nil {
+				t.Errorf("ParseCron(%q) succeeded, want an error", expr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("failed to load time zone: %v", err)
+	}
+	// at reads a wall-clock time at a UTC offset in hours, in loc
+	at := func(s string, offset int, loc *time.Location) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04", s, time.FixedZone("", offset*3600))
+		if err != nil {
+			t.Fatalf("bad time %q: %v", s, err)
+		}
+		return v.In(loc)
+	}
+	utc := func(s string) time.Time { return at(s, 0, time.UTC) }
+	ny := func(s string, offset int) time.Time { return at(s, offset, newYork) }
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "every other day of month",
+			expr: "0 0 */2 * *",
+			from: utc("2026-10-16 12:00"),
+			want: []time.Time{utc("2026-10-17 00:00"), utc("2026-10-19 00:00"), utc("2026-10-21 00:00")},
+		},
+		{
+			name: "every other weekday",
+			expr: "0 0 * * */2", // Sunday, Tuesday, Thursday, Saturday
+			from: utc("2026-10-16 12:00"),
+			want: []time.Time{utc("2026-10-17 00:00"), utc("2026-10-18 00:00"), utc("2026-10-20 00:00")},
+		},
+		{
+			name: "step over a range",
+			expr: "10-40/15 * * * *",
+			from: utc("2026-10-16 12:00"),
+			want: []time.Time{utc("2026-10-16 12:10"), utc("2026-10-16 12:25"), utc("2026-10-16 12:40"), utc("2026-10-16 13:10")},
+		},
+		{
+			name: "day of month or day of week",
+			expr: "0 9 1 * mon",
+			from: utc("2026-10-25 12:00"),
+			want: []time.Time{utc("2026-10-26 09:00"), utc("2026-11-01 09:00"), utc("2026-11-02 09:00")},
+		},
+		{
+			name: "names",
+			expr: "30 6 * JAN,Jul Sat",
+			from: utc("2026-10-16 12:00"),
+			want: []time.Time{utc("2027-01-02 06:30"), utc("2027-01-09 06:30")},
+		},
+		{
+			name: "7 is Sunday",
+			expr: "0 12 * * 7",
+			from: utc("2026-10-16 12:00"),
+			want: []time.Time{utc("2026-10-18 12:00"), utc("2026-10-25 12:00")},
+		},
+		{
+			name: "macro",
+			expr: "@monthly",
+			from: utc("2026-10-16 12:00"),
+			want: []time.Time{utc("2026-11-01 00:00"), utc("2026-12-01 00:00")},
+		},
+		{
+			name: "February 30 never comes",
+			expr: "0 0 30 2 *",
+			from: utc("2026-10-16 12:00"),
+			want: []time.Time{{}},
+		},
+		{
+			name: "spring forward skips the missing time",
+			expr: "30 2 * * *",
+			from: ny("2026-03-07 12:00", -5),
+			want: []time.Time{ny("2026-03-09 02:30", -4)},
+		},
+		{
+			name: "spring forward keeps the next hour",
+			expr: "0 * * * *",
+			from: ny("2026-03-08 01:30", -5),
+			want: []time.Time{ny("2026-03-08 03:00", -4), ny("2026-03-08 04:00", -4)},
+		},
+		{
+			name: "fall back runs a repeated time once",
+			expr: "30 1 * * *",
+			from: ny("2026-10-31 12:00", -4),
+			want: []time.Time{ny("2026-11-01 01:30", -4), ny("2026-11-02 01:30", -5)},
+		},
+		{
+			name: "fall back skips the repeated hour",
+			expr: "*/30 * * * *",
+			from: ny("2026-11-01 01:15", -4),
+			want: []time.Time{ny("2026-11-01 01:30", -4), ny("2026-11-01 02:00", -5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+			}
+			from := tt.from
+			for i, want := range tt.want {
+				got := c.Next(from)
+				if !got.Equal(want) {
+					t.Fatalf("Next #%d after %v = %v, want %v", i+1, from, got, want)
+				}
+				from = got
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"airbnb-scraper/models"
+)
+
+// Missed-run policies: what to do when a planned run was not started on time
+// because the daemon was down or the machine asleep
+const (
+	MissedSkip = "skip" // record the missed run and wait for the next one
+	MissedRun  = "run"  // run once as soon as possible, however many were missed
+)
+
+// Schedule is a recurring scrape of one group of sections
+type Schedule struct {
+	Name                 string   `json:"name"`
+	Cron                 string   `json:"cron"`
+	Timezone             string   `json:"timezone,omitempty"` // IANA name; empty is the local time zone
+	Sections             []string `json:"sections,omitempty"` // empty scrapes SCRAPE_SECTIONS, or discovers them
+	PropertiesPerSection int      `json:"properties_per_section,omitempty"`
+	CheckInDays          int      `json:"check_in_days,omitempty"` // search dates: check in this many days after the run...
+	Nights               int      `json:"nights,omitempty"`        // ...for this many nights; 0 leaves the dates out
+	Jitter               string   `json:"jitter,omitempty"`        // random delay up to this duration, e.g. "15m"
+	Missed               string   `json:"missed,omitempty"`        // skip (default) or run
+
+	cron   *Cron
+	loc    *time.Location
+	jitter time.Duration
+}
+
+// LoadSchedules reads a JSON file of the form {"schedules": [...]} and
+// checks every schedule
+func LoadSchedules(path string) ([]*Schedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schedules: %w", err)
+	}
+	var file struct {
+		Schedules []*Schedule `json:"schedules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse schedules %s: %w", path, err)
+	}
+	if len(file.Schedules) == 0 {
+		return nil, fmt.Errorf("no schedules in %s", path)
+	}
+
+	names := make(map[string]bool)
+	for i, sc := range file.Schedules {
+		if sc.Name == "" {
+			return nil, fmt.Errorf("schedule %d in %s: name is required", i+1, path)
+		}
+		if names[sc.Name] {
+			return nil, fmt.Errorf("schedule %q is defined twice in %s", sc.Name, path)
+		}
+		names[sc.Name] = true
+		if err := sc.init(); err != nil {
+			return nil, fmt.Errorf("schedule %q in %s: %w", sc.Name, path, err)
+		}
+	}
+	return file.Schedules, nil
+}
+
+// init parses and checks the schedule's fields
+func (sc *Schedule) init() error {
+	cron, err := ParseCron(sc.Cron)
+	if err != nil {
+		return err
+	}
+	sc.cron = cron
+
+	sc.loc = time.Local
+	if sc.Timezone != "" {
+		if sc.loc, err = time.LoadLocation(sc.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", sc.Timezone)
+		}
+	}
+	if sc.cron.Next(time.Now().In(sc.loc)).IsZero() {
+		return fmt.Errorf("cron expression %q never matches", sc.Cron)
+	}
+
+	if sc.Jitter != "" {
+		if sc.jitter, err = time.ParseDuration(sc.Jitter); err != nil || sc.jitter < 0 {
+			return fmt.Errorf("jitter must be a positive duration such as 15m, got %q", sc.Jitter)
+		}
+	}
+	switch sc.Missed {
+	case "":
+		sc.Missed = MissedSkip
+	case MissedSkip, MissedRun:
+	default:
+		return fmt.Errorf("missed must be %s or %s, got %q", MissedSkip, MissedRun, sc.Missed)
+	}
+
+	if sc.PropertiesPerSection < 0 || sc.CheckInDays < 0 || sc.Nights < 0 {
+		return fmt.Errorf("properties_per_section, check_in_days and nights cannot be negative")
+	}
+	if sc.CheckInDays > 0 && sc.Nights == 0 {
+		return fmt.Errorf("check_in_days needs nights")
+	}
+	return nil
+}
+
+// overrides are the job overrides of the run planned at the given time, with
+// the search dates counted from its day
+func (sc *Schedule) overrides(planned time.Time) models.JobOverrides {
+	o := models.JobOverrides{Sections: sc.Sections, PropertiesPerSection: sc.PropertiesPerSection}
+	if sc.Nights > 0 {
+		checkIn := planned.In(sc.loc).AddDate(0, 0, sc.CheckInDays)
+		o.CheckIn = checkIn.Format("2006-01-02")
+		o.CheckOut = checkIn.AddDate(0, 0, sc.Nights).Format("2006-01-02")
+	}
+	return o
+}
@@ -0,0 +1,340 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"airbnb-scraper/jobs"
+	"airbnb-scraper/models"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+)
+
+const (
+	// missedGrace is how late a run may start before it counts as missed
+	missedGrace = 2 * time.Minute
+	// maxSleep is the longest the scheduler sleeps without looking at the
+	// clock, so a machine waking from suspend notices missed runs
+	maxSleep = time.Minute
+	// busyRetry is how often a due run retries while a manual job is running
+	busyRetry = 30 * time.Second
+	// maxRuns is how many scheduled runs are kept in memory for the API
+	maxRuns = 500
+	// maxMissedCount bounds the count of missed runs reported after downtime
+	maxMissedCount = 1000
+)
+
+// Scheduler starts scrape jobs on cron schedules. A schedule never overlaps
+// itself: when it fires while its previous run is still waiting or running,
+// the new run is skipped. Runs of different schedules go through the job
+// Manager one at a time, in the order they fired.
+type Scheduler struct {
+	jobs    *jobs.Manager
+	history *storage.JSONLWriter
+	logger  *utils.Logger
+
+	mu      sync.Mutex // guards entries
+	entries []*entry
+	queue   chan *dueRun
+
+	runsMu sync.Mutex
+	runs   []*models.ScheduledRun // oldest first
+}
+
+// entry is a schedule and its next planned run
+type entry struct {
+	schedule *Schedule
+	next     time.Time // cron time, in the schedule's time zone
+	fireAt   time.Time // next plus jitter
+	pending  bool      // a run is queued or running
+}
+
+// dueRun is a run waiting for the job Manager
+type dueRun struct {
+	entry   *entry
+	planned time.Time
+	reason  string
+}
+
+// New creates a Scheduler. Its run history is read from and appended to
+// historyPath; the last recorded run of each schedule decides whether runs
+// were missed while the daemon was down.
+func New(schedules []*Schedule, manager *jobs.Manager, historyPath string, logger *utils.Logger) (*Scheduler, error) {
+	runs, err := storage.ReadScheduledRuns(historyPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(runs) > maxRuns {
+		runs = runs[len(runs)-maxRuns:]
+	}
+
+	s := &Scheduler{
+		jobs:    manager,
+		history: storage.NewJSONLWriter(historyPath, logger),
+		logger:  logger,
+		runs:    runs,
+		queue:   make(chan *dueRun, len(schedules)),
+	}
+	now := time.Now()
+	for _, sc := range schedules {
+		e := &entry{schedule: sc}
+		if last := s.lastPlanned(sc.Name); !last.IsZero() {
+			e.next = sc.cron.Next(last.In(sc.loc))
+		} else {
+			e.next = sc.cron.Next(now.In(sc.loc))
+		}
+		e.fireAt = e.next.Add(sc.randomJitter())
+		s.entries = append(s.entries, e)
+	}
+	return s, nil
+}
+
+// Run fires schedules until ctx is done. It then cancels the scheduled job
+// that is running, if any, and returns once it has stopped.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.runQueue(ctx)
+	}()
+
+	for _, e := range s.entries {
+		s.logger.Info("Schedule %s (%s): next run at %s", e.schedule.Name, e.schedule.Cron,
+			e.fireAt.Format("2006-01-02 15:04:05 MST"))
+	}
+
+	for {
+		s.mu.Lock()
+		wake := time.Time{}
+		for _, e := range s.entries {
+			if wake.IsZero() || e.fireAt.Before(wake) {
+				wake = e.fireAt
+			}
+		}
+		s.mu.Unlock()
+
+		wait := time.Until(wake)
+		if wait > maxSleep {
+			wait = maxSleep
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			wg.Wait()
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		s.mu.Lock()
+		for _, e := range s.entries {
+			if !e.fireAt.After(now) {
+				s.fire(e, now)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// fire handles a schedule whose time has come: it queues a run, or records
+// why it does not. The caller holds s.mu.
+func (s *Scheduler) fire(e *entry, now time.Time) {
+	sc := e.schedule
+	first, planned, late := e.next, e.next, now.Sub(e.fireAt)
+
+	// Every cron time up to now is one run; only the latest is planned
+	missed := 0
+	for t := e.next; !t.IsZero() && !t.After(now) && missed < maxMissedCount; t = sc.cron.Next(t) {
+		planned = t
+		missed++
+	}
+	e.next = sc.cron.Next(now.In(sc.loc))
+	e.fireAt = e.next.Add(sc.randomJitter())
+
+	reason := ""
+	if late > missedGrace {
+		if missed > 1 {
+			reason = fmt.Sprintf("%d runs missed since %s", missed, first.Format("2006-01-02 15:04 MST"))
+		} else {
+			reason = fmt.Sprintf("run missed by %s", late.Round(time.Second))
+		}
+		if sc.Missed != MissedRun {
+			s.logger.Warn("Schedule %s: %s, next run at %s", sc.Name, reason, e.fireAt.Format("2006-01-02 15:04:05 MST"))
+			s.record(&models.ScheduledRun{Schedule: sc.Name, PlannedAt: planned, Status: models.ScheduleMissed, Reason: reason})
+			return
+		}
+		reason = "catch-up: " + reason
+	}
+
+	if e.pending {
+		s.logger.Warn("Schedule %s: previous run still going, skipping the run planned at %s",
+			sc.Name, planned.Format("15:04 MST"))
+		s.record(&models.ScheduledRun{Schedule: sc.Name, PlannedAt: planned, Status: models.ScheduleSkipped,
+			Reason: "previous run still going"})
+		return
+	}
+	e.pending = true
+	s.queue <- &dueRun{entry: e, planned: planned, reason: reason} // never blocks: one per schedule at most
+}
+
+// runQueue starts due runs one at a time until ctx is done
+func (s *Scheduler) runQueue(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case run := <-s.queue:
+			s.runDue(ctx, run)
+			s.mu.Lock()
+			run.entry.pending = false
+			s.mu.Unlock()
+		}
+	}
+}
+
+// runDue starts the job of a due run, waits for it and records the outcome.
+// While a job started through the API is running, it waits its turn.
+func (s *Scheduler) runDue(ctx context.Context, run *dueRun) {
+	sc := run.entry.schedule
+	var job *models.Job
+	var err error
+	for {
+		job, err = s.jobs.StartScheduled(sc.Name, sc.overrides(run.planned))
+		if !errors.Is(err, jobs.ErrJobRunning) {
+			break
+		}
+		s.logger.Debug("Schedule %s: another job is running, retrying in %s", sc.Name, busyRetry)
+		select {
+		case <-ctx.Done():
+			return // not recorded, so a restart sees it as missed
+		case <-time.After(busyRetry):
+		}
+	}
+	if err != nil {
+		s.logger.Error("Schedule %s: cannot start its job: %v", sc.Name, err)
+		s.record(&models.ScheduledRun{Schedule: sc.Name, PlannedAt: run.planned, Status: models.JobFailed,
+			Reason: run.reason, Error: err.Error()})
+		return
+	}
+	s.logger.Info("Schedule %s: started job %s", sc.Name, job.ID)
+
+	finished, err := s.jobs.Wait(ctx, job.ID)
+	if err != nil {
+		// The daemon is stopping: cancel the job and record how it ended
+		_, _ = s.jobs.Cancel(job.ID)
+		if finished, err = s.jobs.Wait(context.Background(), job.ID); err != nil {
+			return
+		}
+	}
+	s.record(&models.ScheduledRun{
+		Schedule:      sc.Name,
+		PlannedAt:     run.planned,
+		Status:        finished.Status,
+		Reason:        run.reason,
+		JobID:         finished.ID,
+		StartedAt:     &finished.StartedAt,
+		FinishedAt:    finished.FinishedAt,
+		CleanListings: finished.CleanListings,
+		Error:         finished.Error,
+	})
+}
+
+// record appends a run to the history, in memory and on disk. Losing the
+// file record only affects missed-run detection after a restart.
+func (s *Scheduler) record(run *models.ScheduledRun) {
+	if err := s.history.AppendScheduledRun(run); err != nil {
+		s.logger.Error("Failed to record scheduled run: %v", err)
+	}
+	s.runsMu.Lock()
+	defer s.runsMu.Unlock()
+	s.runs = append(s.runs, run)
+	if len(s.runs) > maxRuns {
+		s.runs = s.runs[len(s.runs)-maxRuns:]
+	}
+}
+
+// lastPlanned is the planned time of the latest recorded run of a schedule
+func (s *Scheduler) lastPlanned(name string) time.Time {
+	var last time.Time
+	for _, r := range s.runs {
+		if r.Schedule == name && r.PlannedAt.After(last) {
+			last = r.PlannedAt
+		}
+	}
+	return last
+}
+
+// randomJitter is a random delay below the schedule's jitter
+func (sc *Schedule) randomJitter() time.Duration {
+	if sc.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(sc.jitter)))
+}
+
+// Schedules returns every schedule with its next run and its latest
+// recorded run
+func (s *Scheduler) Schedules() []*models.ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*models.ScheduleStatus, len(s.entries))
+	for i, e := range s.entries {
+		list[i] = s.status(e)
+		if runs := s.scheduleRuns(e.schedule.Name, 1); len(runs) > 0 {
+			list[i].LastRun = runs[0]
+		}
+	}
+	return list
+}
+
+// Schedule returns one schedule with its remembered runs, latest first, or
+// nil when there is no schedule of that name
+func (s *Scheduler) Schedule(name string) *models.ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if e.schedule.Name == name {
+			st := s.status(e)
+			st.Runs = s.scheduleRuns(name, 0)
+			if len(st.Runs) > 0 {
+				st.LastRun = st.Runs[0]
+			}
+			return st
+		}
+	}
+	return nil
+}
+
+// status describes an entry. The caller holds s.mu.
+func (s *Scheduler) status(e *entry) *models.ScheduleStatus {
+	sc := e.schedule
+	return &models.ScheduleStatus{
+		Name:      sc.Name,
+		Cron:      sc.Cron,
+		Timezone:  sc.loc.String(),
+		Sections:  sc.Sections,
+		NextRunAt: e.fireAt,
+		Pending:   e.pending,
+	}
+}
+
+// scheduleRuns returns the last n recorded runs of a schedule, latest first,
+// or all of them when n is 0
+func (s *Scheduler) scheduleRuns(name string, n int) []*models.ScheduledRun {
+	s.runsMu.Lock()
+	defer s.runsMu.Unlock()
+	var list []*models.ScheduledRun
+	for i := len(s.runs) - 1; i >= 0 && (n == 0 || len(list) < n); i-- {
+		if s.runs[i].Schedule == name {
+			list = append(list, s.runs[i])
+		}
+	}
+	return list
+}
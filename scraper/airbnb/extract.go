@@ -0,0 +1,179 @@
+package airbnb
+
+import (
+	"time"
+
+	"airbnb-scraper/models"
+)
+
+// The extraction scripts are shared between live scraping and re-extraction of
+// archived pages, so a fix here applies to both.
+
+// cardsJS extracts all listing cards from a search result page
+const cardsJS = `
+	(function() {
+		var results = [];
+
+		// Find all listing containers using multiple selector strategies
+		var containers = [];
+		var strategy = 'A';
+
+		// Strategy A: official test id
+		var a = document.querySelectorAll('[data-testid="card-container"]');
+		if (a.length > 0) { containers = Array.from(a); }
+
+		// Strategy B: itemprop
+		if (containers.length === 0) {
+			strategy = 'B';
+			containers = Array.from(document.querySelectorAll('[itemprop="itemListElement"]'));
+		}
+
+		// Strategy C: parent div of any /rooms/ link
+		if (containers.length === 0) {
+			strategy = 'C';
+			var seen = new Set();
+			document.querySelectorAll('a[href*="/rooms/"]').forEach(function(a) {
+				var p = a.parentElement;
+				for (var i = 0; i < 5; i++) {
+					if (!p) break;
+					if (p.querySelectorAll('a[href*="/rooms/"]').length === 1) {
+						if (!seen.has(p)) { seen.add(p); containers.push(p); }
+						break;
+					}
+					p = p.parentElement;
+				}
+			});
+		}
+
+		containers.forEach(function(card) {
+			// ── Title ──────────────────────────────────────────────
+			var titleEl =
+				card.querySelector('[data-testid="listing-card-title"]') ||
+				card.querySelector('[id^="title_"]') ||
+				card.querySelector('[itemprop="name"]');
+			var title = titleEl ? titleEl.innerText.trim() : '';
+
+			// ── Subtitle (e.g. "2 bedrooms · 3 beds") ────────────
+			var subtitle = Array.from(card.querySelectorAll('[data-testid="listing-card-subtitle"]'))
+				.map(function(el) { return el.innerText.replace(/\s+/g, ' ').trim(); })
+				.filter(function(t) { return t; })
+				.join(' · ');
+
+			// ── Price ──────────────────────────────────────────────
+			var price = '';
+			// look for aria-label describing the price ("$95 per night, originally $120")
+			var priceAria = card.querySelector('[aria-label*="per night"], [aria-label*="total"]');
+			if (priceAria) { price = priceAria.getAttribute('aria-label'); }
+			// fallback: the whole price row, keeping crossed-out and total amounts
+			if (!price) {
+				var priceRow = card.querySelector('[data-testid="price-availability-row"]');
+				if (priceRow) { price = priceRow.innerText.replace(/\s+/g, ' ').trim(); }
+			}
+			// fallback: first span starting with a currency marker ($, €, ฿, RM, ...)
+			if (!price) {
+				card.querySelectorAll('span').forEach(function(sp) {
+					var t = sp.innerText.trim();
+					if (!price && /^(?:[^\w\s]{1,2}|[A-Z]{1,3}\$?|Rp)\s?\d/.test(t)) {
+						price = t;
+					}
+				});
+			}
+
+			// ── Rating ─────────────────────────────────────────────
+			var rating = '';
+			var ratingAria = card.querySelector('[aria-label*="out of 5"]');
+			if (ratingAria) { rating = ratingAria.getAttribute('aria-label'); }
+			// fallback: "4.82 (312)" or a "New" label, keeping the review count
+			if (!rating) {
+				card.querySelectorAll('span').forEach(function(sp) {
+					var t = sp.innerText.trim();
+					if (!rating && (/^[0-5][.,]\d{1,2}(\s*\(\d[\d,.]*\))?$/.test(t) || /^New\b/.test(t))) {
+						rating = t;
+					}
+				});
+			}
+
+			// ── URL ────────────────────────────────────────────────
+			var linkEl = card.querySelector('a[href*="/rooms/"]');
+			var url = linkEl ? linkEl.href : '';
+
+			// ── Photo (first image; used to spot the same property under two IDs) ──
+			var img = card.querySelector('img[src*="muscache"]') || card.querySelector('img');
+			var photo = img ? (img.currentSrc || img.src || '') : '';
+
+			// ── Superhost badge ────────────────────────────────────
+			var superhost = /\bSuperhost\b/.test(card.innerText);
+
+			// ── Location ───────────────────────────────────────────
+			var location = '';
+			if (title.includes(' in ')) {
+				location = title.split(' in ').slice(1).join(' in ');
+			}
+
+			if (title || url) {
+				results.push({title:title, subtitle:subtitle, price:price, rating:rating, url:url, photo:photo, superhost:superhost, location:location, strategy:strategy});
+			}
+		});
+
+		return results;
+	})()
+`
+
+// nextPageJS returns the href of the pagination "Next" link, if any
+const nextPageJS = `
+	(function() {
+		var n = document.querySelector('a[aria-label="Next"]') ||
+		        document.querySelector('[data-testid="pagination-next-btn"]') ||
+		        document.querySelector('a[href*="items_offset"]');
+		return n ? n.href : '';
+	})()
+`
+
+// descriptionJS extracts the description from a listing detail page
+const descriptionJS = `
+	(function() {
+		var el =
+			document.querySelector('[data-section-id="DESCRIPTION_DEFAULT"] span') ||
+			document.querySelector('[data-section-id="OVERVIEW_DEFAULT"] h1') ||
+			document.querySelector('h1');
+		return el ? el.innerText.trim() : '';
+	})()
+`
+
+// card mirrors the objects returned by cardsJS
+type card struct {
+	Title     string `json:"title"`
+	Subtitle  string `json:"subtitle"`
+	Price     string `json:"price"`
+	Rating    string `json:"rating"`
+	URL       string `json:"url"`
+	Photo     string `json:"photo"`
+	Superhost bool   `json:"superhost"`
+	Location  string `json:"location"`
+	Strategy  string `json:"strategy"` // selector strategy that found the card: A, B or C
+}
+
+// cardsToListings converts extracted cards into RawListings for a section
+func cardsToListings(cards []card, sectionName string, scrapedAt time.Time) []*models.RawListing {
+	var listings []*models.RawListing
+	for _, c := range cards {
+		loc := c.Location
+		if loc == "" {
+			loc = sectionName
+		}
+		listings = append(listings, &models.RawListing{
+			Platform:  "Airbnb",
+			Title:     c.Title,
+			RawPrice:  c.Price,
+			Location:  loc,
+			Section:   sectionName,
+			Subtitle:  c.Subtitle,
+			RawRating: c.Rating,
+			URL:       c.URL,
+			PhotoURL:  c.Photo,
+			Superhost: c.Superhost,
+			ScrapedAt: scrapedAt,
+		})
+	}
+	return listings
+}
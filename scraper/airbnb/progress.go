@@ -0,0 +1,20 @@
+package airbnb
+
+// Progress is told about a scrape as it happens, e.g. to report the progress
+// of a job. Methods are called from the scraping goroutine and must not block.
+type Progress interface {
+	SectionsPlanned(names []string)
+	PageFetched(section string, page, cards int)
+	ListingCollected(section string)
+	SectionDone(section string, collected int)
+	Error(section string, err error)
+}
+
+// noProgress discards progress when no Progress is set
+type noProgress struct{}
+
+func (noProgress) SectionsPlanned([]string)     {}
+func (noProgress) PageFetched(string, int, int) {}
+func (noProgress) ListingCollected(string)      {}
+func (noProgress) SectionDone(string, int)      {}
+func (noProgress) Error(string, error)          {}
@@ -0,0 +1,109 @@
+package airbnb
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"airbnb-scraper/models"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+var (
+	scriptTagRegex = regexp.MustCompile(`(?is)<script\b.*?</script>`)
+	headTagRegex   = regexp.MustCompile(`(?i)<head[^>]*>`)
+)
+
+// ReextractArchived runs the current extraction scripts against archived pages
+// instead of the live site. Search pages produce listings; detail pages fetched
+// for the same listing URLs supply descriptions, as enrichDetail would.
+func (s *AirbnbScraper) ReextractArchived(archive *storage.HTMLArchive, entries []storage.ArchiveEntry) ([]*models.RawListing, error) {
+	ctx, cancel := s.NewBrowserContext(context.Background())
+	defer cancel()
+
+	ctx, cancelTimeout := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancelTimeout()
+
+	// Latest detail page per listing, keyed by canonical listing ID
+	type detail struct {
+		hash string
+		desc string
+	}
+	details := make(map[string]detail)
+	for _, e := range entries {
+		if e.Kind != storage.ArchiveKindDetail {
+			continue
+		}
+		var desc string
+		if err := s.evaluateArchived(ctx, archive, e, descriptionJS, &desc); err != nil {
+			s.logger.Warn("Re-extract failed for detail page %s: %v", e.URL, err)
+			continue
+		}
+		details[utils.ListingKey(e.URL)] = detail{hash: e.Hash, desc: desc}
+	}
+
+	var listings []*models.RawListing
+	for _, e := range entries {
+		if e.Kind != storage.ArchiveKindSearch {
+			continue
+		}
+		var cards []card
+		if err := s.evaluateArchived(ctx, archive, e, cardsJS, &cards); err != nil {
+			s.logger.Warn("Re-extract failed for search page %s: %v", e.URL, err)
+			continue
+		}
+
+		for _, l := range cardsToListings(cards, e.Section, e.FetchedAt) {
+			l.PageHash = e.Hash
+			if d, ok := details[utils.ListingKey(l.URL)]; ok {
+				l.DetailHash = d.hash
+				if d.desc != "" && !strings.EqualFold(strings.TrimSpace(d.desc), strings.TrimSpace(l.Title)) {
+					l.Description = d.desc
+				}
+			}
+			listings = append(listings, l)
+		}
+		s.logger.Info("Re-extracted %d cards from %s (%s)", len(cards), e.URL, e.Hash[:12])
+	}
+
+	return listings, nil
+}
+
+// evaluateArchived loads an archived page into the browser and evaluates script against it
+func (s *AirbnbScraper) evaluateArchived(ctx context.Context, archive *storage.HTMLArchive, entry storage.ArchiveEntry, script string, res interface{}) error {
+	body, err := archive.Get(entry.Hash)
+	if err != nil {
+		return err
+	}
+	doc := prepareArchivedHTML(string(body), entry.URL)
+
+	return chromedp.Run(ctx,
+		chromedp.Navigate("about:blank"),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			tree, err := page.GetFrameTree().Do(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get frame tree: %w", err)
+			}
+			return page.SetDocumentContent(tree.Frame.ID, doc).Do(ctx)
+		}),
+		chromedp.Evaluate(script, res),
+	)
+}
+
+// prepareArchivedHTML strips scripts so the snapshot stays static and adds a
+// <base> so relative links resolve to the URL the page was fetched from
+func prepareArchivedHTML(doc, pageURL string) string {
+	doc = scriptTagRegex.ReplaceAllString(doc, "")
+	base := fmt.Sprintf(`<base href="%s">`, html.EscapeString(pageURL))
+	if loc := headTagRegex.FindStringIndex(doc); loc != nil {
+		return doc[:loc[1]] + base + doc[loc[1]:]
+	}
+	return base + doc
+}
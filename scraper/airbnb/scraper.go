@@ -3,12 +3,14 @@ package airbnb
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"strings"
-	"sync"
 	"time"
 
 	"airbnb-scraper/config"
+	"airbnb-scraper/metrics"
 	"airbnb-scraper/models"
+	"airbnb-scraper/storage"
 	"airbnb-scraper/utils"
 
 	"github.com/chromedp/chromedp"
@@ -25,22 +27,46 @@ type AirbnbScraper struct {
 	cfg         *config.Config
 	logger      *utils.Logger
 	rateLimiter *utils.RateLimiter
-	mu          sync.Mutex
-	seenURLs    map[string]bool
+	archive     *storage.HTMLArchive // optional; nil disables page archiving
+	seen        *utils.URLTracker    // keyed by canonical listing ID
+	progress    Progress
+	metrics     *metrics.Metrics // optional; nil records nothing
+	details     bool             // fetch detail pages for listings without a description
 }
 
-// NewAirbnbScraper creates a new AirbnbScraper
-func NewAirbnbScraper(cfg *config.Config, logger *utils.Logger) *AirbnbScraper {
+// NewAirbnbScraper creates a new AirbnbScraper. archive may be nil.
+func NewAirbnbScraper(cfg *config.Config, archive *storage.HTMLArchive, logger *utils.Logger) *AirbnbScraper {
 	return &AirbnbScraper{
 		cfg:         cfg,
 		logger:      logger,
 		rateLimiter: utils.NewRateLimiter(cfg.RateLimitDelay),
-		seenURLs:    make(map[string]bool),
+		archive:     archive,
+		seen:        utils.NewURLTracker(),
+		progress:    noProgress{},
+		details:     true,
 	}
 }
 
-// newBrowserContext creates a fresh headless browser context
-func (s *AirbnbScraper) newBrowserContext() (context.Context, context.CancelFunc) {
+// SetProgress reports the progress of later scrapes to p
+func (s *AirbnbScraper) SetProgress(p Progress) {
+	s.progress = p
+}
+
+// SetMetrics records pages, retries and rate limiter waits of later
+// scrapes in m
+func (s *AirbnbScraper) SetMetrics(m *metrics.Metrics) {
+	s.metrics = m
+}
+
+// SetDetailPages turns fetching detail pages during ScrapeSection on or off.
+// Queue workers turn it off and fetch them as separate tasks.
+func (s *AirbnbScraper) SetDetailPages(enabled bool) {
+	s.details = enabled
+}
+
+// NewBrowserContext creates a fresh headless browser context, closed when
+// parent is done. PlanSections, ScrapeSection and FetchDetail run in one.
+func (s *AirbnbScraper) NewBrowserContext(parent context.Context) (context.Context, context.CancelFunc) {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		chromedp.Flag("headless", true),
 		chromedp.Flag("no-sandbox", true),
@@ -52,7 +78,7 @@ func (s *AirbnbScraper) newBrowserContext() (context.Context, context.CancelFunc
 		chromedp.WindowSize(1440, 900),
 	)
 
-	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(parent, opts...)
 	ctx, cancelCtx := chromedp.NewContext(
 		allocCtx,
 		chromedp.WithLogf(func(string, ...interface{}) {}), // suppress noise
@@ -63,54 +89,85 @@ func (s *AirbnbScraper) newBrowserContext() (context.Context, context.CancelFunc
 	}
 }
 
-// Scrape is the main entry point
-func (s *AirbnbScraper) Scrape() ([]*models.RawListing, error) {
+// Scrape is the main entry point. Cancelling ctx stops the scrape and
+// returns the listings collected so far with ctx's error.
+func (s *AirbnbScraper) Scrape(ctx context.Context) ([]*models.RawListing, error) {
 	s.logger.Info("Starting Airbnb scraper...")
 
-	ctx, cancel := s.newBrowserContext()
+	ctx, cancel := s.NewBrowserContext(ctx)
 	defer cancel()
 
 	ctx, cancelTimeout := context.WithTimeout(ctx, 30*time.Minute)
 	defer cancelTimeout()
 
-	// Step 1: discover sections from homepage
-	sections, err := s.discoverSections(ctx)
-	if err != nil || len(sections) == 0 {
-		s.logger.Warn("Homepage JS discovery failed or returned 0 sections, using fallback search URLs...")
-		sections = s.fallbackSections()
-	}
-
-	s.logger.Info("Scraping %d location sections...", len(sections))
+	// Step 1: plan the sections
+	sections := s.PlanSections(ctx)
+	names := make([]string, len(sections))
 	for i, sec := range sections {
-		s.logger.Info("  [%d] %s", i+1, sec.Name)
+		names[i] = sec.Name
 	}
+	s.progress.SectionsPlanned(names)
 
 	// Step 2: scrape each section
 	var allListings []*models.RawListing
 	for _, section := range sections {
-		s.rateLimiter.Wait()
-		listings, err := s.scrapeSection(ctx, section)
+		if ctx.Err() != nil {
+			break
+		}
+		s.wait()
+		listings, err := s.ScrapeSection(ctx, section)
 		if err != nil {
-			s.logger.Error("Section '%s' failed: %v", section.Name, err)
+			s.logger.With("section", section.Name).Error("Section '%s' failed: %v", section.Name, err)
+			s.progress.Error(section.Name, err)
 			continue
 		}
 		allListings = append(allListings, listings...)
-		s.logger.Info("Section '%s' done: %d listings (total: %d)",
+		s.progress.SectionDone(section.Name, len(listings))
+		s.metrics.SectionScraped(section.Name, len(listings))
+		s.logger.With("section", section.Name).Info("Section '%s' done: %d listings (total: %d)",
 			section.Name, len(listings), len(allListings))
 	}
+	if err := ctx.Err(); err != nil {
+		s.logger.Warn("Scraping stopped after %d raw listings: %v", len(allListings), err)
+		return allListings, err
+	}
 
 	s.logger.Info("Scraping complete. Total raw listings: %d", len(allListings))
 	return allListings, nil
 }
 
+// PlanSections returns the sections to scrape: the configured ones, or those
+// discovered on the homepage, or the fallback list, with the search dates
+// applied
+func (s *AirbnbScraper) PlanSections(ctx context.Context) []LocationSection {
+	var sections []LocationSection
+	if len(s.cfg.Sections) > 0 {
+		sections = s.configuredSections()
+	} else {
+		var err error
+		sections, err = s.discoverSections(ctx)
+		if err != nil || len(sections) == 0 {
+			s.logger.Warn("Homepage JS discovery failed or returned 0 sections, using fallback search URLs...")
+			sections = s.fallbackSections()
+		}
+	}
+
+	s.logger.Info("Scraping %d location sections...", len(sections))
+	for i, sec := range sections {
+		sections[i].URL = s.withSearchDates(sec.URL)
+		s.logger.Info("  [%d] %s", i+1, sec.Name)
+	}
+	return sections
+}
+
 // discoverSections tries to extract section links from the Airbnb homepage via JS
 func (s *AirbnbScraper) discoverSections(ctx context.Context) ([]LocationSection, error) {
 	s.logger.Info("Loading Airbnb homepage...")
 
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(s.cfg.AirbnbURL),
-		chromedp.Sleep(6*time.Second),
-	)
+	err := s.navigate(ctx, metrics.PageHomepage, s.cfg.AirbnbURL)
+	if err == nil {
+		err = chromedp.Run(ctx, chromedp.Sleep(6*time.Second))
+	}
 	if err != nil {
 		return nil, fmt.Errorf("homepage load failed: %w", err)
 	}
@@ -205,25 +262,80 @@ func (s *AirbnbScraper) fallbackSections() []LocationSection {
 	}
 }
 
-// scrapeSection collects PropertiesPerPage listings from a section, paginating as needed
-func (s *AirbnbScraper) scrapeSection(ctx context.Context, section LocationSection) ([]*models.RawListing, error) {
-	s.logger.Info("Scraping: %s", section.Name)
+// configuredSections turns the configured sections into search URLs. An
+// entry is a search URL, or a place name looked up among the fallback
+// sections and otherwise searched for by name.
+func (s *AirbnbScraper) configuredSections() []LocationSection {
+	known := make(map[string]LocationSection)
+	for _, sec := range s.fallbackSections() {
+		known[strings.ToLower(sec.Name)] = sec
+	}
+
+	base := strings.TrimSuffix(s.cfg.AirbnbURL, "/") + "/s/"
+	sections := make([]LocationSection, 0, len(s.cfg.Sections))
+	for _, entry := range s.cfg.Sections {
+		switch sec, ok := known[strings.ToLower(entry)]; {
+		case strings.HasPrefix(entry, "http://") || strings.HasPrefix(entry, "https://"):
+			sections = append(sections, LocationSection{Name: entry, URL: entry})
+		case ok:
+			sections = append(sections, sec)
+		default:
+			path := url.PathEscape(strings.ReplaceAll(entry, " ", "-"))
+			sections = append(sections, LocationSection{Name: entry, URL: base + path + "/homes"})
+		}
+	}
+	return sections
+}
+
+// withSearchDates adds the configured check-in and check-out dates to a
+// search URL, so prices are quoted for that stay
+func (s *AirbnbScraper) withSearchDates(searchURL string) string {
+	if s.cfg.SearchCheckIn == "" && s.cfg.SearchCheckOut == "" {
+		return searchURL
+	}
+	u, err := url.Parse(searchURL)
+	if err != nil {
+		return searchURL
+	}
+	q := u.Query()
+	if s.cfg.SearchCheckIn != "" {
+		q.Set("checkin", s.cfg.SearchCheckIn)
+	}
+	if s.cfg.SearchCheckOut != "" {
+		q.Set("checkout", s.cfg.SearchCheckOut)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// ScrapeSection collects PropertiesPerPage listings from a section, paginating
+// as needed. Listings this scraper already collected are skipped. It fails
+// only when the first page cannot be loaded.
+func (s *AirbnbScraper) ScrapeSection(ctx context.Context, section LocationSection) ([]*models.RawListing, error) {
+	log := s.logger.With("section", section.Name)
+	log.Info("Scraping: %s", section.Name)
 
 	var collected []*models.RawListing
 	currentURL := section.URL
 	page := 1
 
-	for len(collected) < s.cfg.PropertiesPerPage {
-		s.logger.Info("  [%s] page %d (have %d/%d)...",
+	for len(collected) < s.cfg.PropertiesPerPage && ctx.Err() == nil {
+		pageLog := log.With("page", page)
+		pageLog.Info("  [%s] page %d (have %d/%d)...",
 			section.Name, page, len(collected), s.cfg.PropertiesPerPage)
 
-		listings, nextURL, err := s.scrapePage(ctx, currentURL, section.Name)
+		listings, nextURL, err := s.scrapePage(ctx, pageLog, currentURL, section.Name)
 		if err != nil {
-			s.logger.Error("  Page %d error: %v", page, err)
+			if page == 1 {
+				return nil, fmt.Errorf("first page failed: %w", err)
+			}
+			pageLog.Error("  Page %d error: %v", page, err)
+			s.progress.Error(section.Name, err)
 			break
 		}
+		s.progress.PageFetched(section.Name, page, len(listings))
 		if len(listings) == 0 {
-			s.logger.Warn("  No listings on page %d", page)
+			pageLog.Warn("  No listings on page %d", page)
 			break
 		}
 
@@ -231,20 +343,17 @@ func (s *AirbnbScraper) scrapeSection(ctx context.Context, section LocationSecti
 			if len(collected) >= s.cfg.PropertiesPerPage {
 				break
 			}
-			s.mu.Lock()
-			if s.seenURLs[l.URL] {
-				s.mu.Unlock()
+			if !s.seen.Add(utils.ListingKey(l.URL)) {
 				continue
 			}
-			s.seenURLs[l.URL] = true
-			s.mu.Unlock()
 
 			// Optionally enrich from detail page
-			if l.Description == "" && l.URL != "" {
-				s.rateLimiter.Wait()
-				s.enrichDetail(ctx, l)
+			if s.details && l.Description == "" && l.URL != "" {
+				s.wait()
+				s.enrichDetail(ctx, log, l)
 			}
 			collected = append(collected, l)
+			s.progress.ListingCollected(section.Name)
 		}
 
 		if len(collected) >= s.cfg.PropertiesPerPage || nextURL == "" {
@@ -252,184 +361,132 @@ func (s *AirbnbScraper) scrapeSection(ctx context.Context, section LocationSecti
 		}
 		currentURL = nextURL
 		page++
-		s.rateLimiter.Wait()
+		s.wait()
 	}
 
 	return collected, nil
 }
 
 // scrapePage navigates to a search result page and extracts all listing cards
-func (s *AirbnbScraper) scrapePage(ctx context.Context, pageURL, sectionName string) ([]*models.RawListing, string, error) {
+func (s *AirbnbScraper) scrapePage(ctx context.Context, log *utils.Logger, pageURL, sectionName string) ([]*models.RawListing, string, error) {
 	var listings []*models.RawListing
 	var nextURL string
+	log = log.With("url", pageURL)
 
+	attempt := 0
 	err := utils.RetryWithBackoff(s.cfg.MaxRetries, func() error {
-		if err := chromedp.Run(ctx,
-			chromedp.Navigate(pageURL),
-			chromedp.Sleep(5*time.Second),
-		); err != nil {
+		if attempt++; attempt > 1 {
+			s.metrics.Retry("search_page")
+		}
+		err := s.navigate(ctx, metrics.PageSearch, pageURL)
+		if err == nil {
+			err = chromedp.Run(ctx, chromedp.Sleep(5*time.Second))
+		}
+		if err != nil {
 			return fmt.Errorf("navigate failed: %w", err)
 		}
 
 		// Try to wait for cards — but don't block if they don't appear
 		_ = chromedp.Run(ctx, chromedp.WaitVisible(`[data-testid="card-container"]`, chromedp.ByQuery))
 
-		type card struct {
-			Title    string `json:"title"`
-			Price    string `json:"price"`
-			Rating   string `json:"rating"`
-			URL      string `json:"url"`
-			Location string `json:"location"`
-		}
 		var cards []card
+		if err := chromedp.Run(ctx, chromedp.Evaluate(cardsJS, &cards)); err != nil {
+			return fmt.Errorf("JS extraction failed: %w", err)
+		}
 
-		if err := chromedp.Run(ctx, chromedp.Evaluate(`
-			(function() {
-				var results = [];
-
-				// Find all listing containers using multiple selector strategies
-				var containers = [];
-
-				// Strategy A: official test id
-				var a = document.querySelectorAll('[data-testid="card-container"]');
-				if (a.length > 0) { containers = Array.from(a); }
-
-				// Strategy B: itemprop
-				if (containers.length === 0) {
-					containers = Array.from(document.querySelectorAll('[itemprop="itemListElement"]'));
-				}
-
-				// Strategy C: parent div of any /rooms/ link
-				if (containers.length === 0) {
-					var seen = new Set();
-					document.querySelectorAll('a[href*="/rooms/"]').forEach(function(a) {
-						var p = a.parentElement;
-						for (var i = 0; i < 5; i++) {
-							if (!p) break;
-							if (p.querySelectorAll('a[href*="/rooms/"]').length === 1) {
-								if (!seen.has(p)) { seen.add(p); containers.push(p); }
-								break;
-							}
-							p = p.parentElement;
-						}
-					});
-				}
-
-				containers.forEach(function(card) {
-					// ── Title ──────────────────────────────────────────────
-					var titleEl =
-						card.querySelector('[data-testid="listing-card-title"]') ||
-						card.querySelector('[id^="title_"]') ||
-						card.querySelector('[itemprop="name"]');
-					var title = titleEl ? titleEl.innerText.trim() : '';
-
-					// ── Price ──────────────────────────────────────────────
-					var price = '';
-					// look for aria-label containing "per night"
-					var priceAria = card.querySelector('[aria-label*="per night"]');
-					if (priceAria) { price = priceAria.getAttribute('aria-label'); }
-					// fallback: first span starting with $
-					if (!price) {
-						card.querySelectorAll('span').forEach(function(sp) {
-							if (!price && sp.innerText.trim().startsWith('$')) {
-								price = sp.innerText.trim();
-							}
-						});
-					}
-
-					// ── Rating ─────────────────────────────────────────────
-					var rating = '';
-					var ratingAria = card.querySelector('[aria-label*="out of 5"]');
-					if (ratingAria) { rating = ratingAria.getAttribute('aria-label'); }
-					if (!rating) {
-						card.querySelectorAll('span').forEach(function(sp) {
-							if (!rating && /^[345]\.\d{1,2}$/.test(sp.innerText.trim())) {
-								rating = sp.innerText.trim();
-							}
-						});
-					}
-
-					// ── URL ────────────────────────────────────────────────
-					var linkEl = card.querySelector('a[href*="/rooms/"]');
-					var url = linkEl ? linkEl.href : '';
-
-					// ── Location ───────────────────────────────────────────
-					var location = '';
-					if (title.includes(' in ')) {
-						location = title.split(' in ').slice(1).join(' in ');
-					}
-
-					if (title || url) {
-						results.push({title:title, price:price, rating:rating, url:url, location:location});
-					}
-				});
+		pageHash := s.archivePage(ctx, storage.ArchiveEntry{
+			Kind:    storage.ArchiveKindSearch,
+			URL:     pageURL,
+			Section: sectionName,
+		})
 
-				return results;
-			})()
-		`, &cards)); err != nil {
-			return fmt.Errorf("JS extraction failed: %w", err)
+		strategy := ""
+		if len(cards) > 0 {
+			strategy = cards[0].Strategy
 		}
+		s.metrics.ExtractionStrategy(strategy)
+		log.Debug("  Extracted %d cards (strategy %s)", len(cards), strategy)
 
-		listings = nil
-		for _, c := range cards {
-			loc := c.Location
-			if loc == "" {
-				loc = sectionName
-			}
-			listings = append(listings, &models.RawListing{
-				Platform:  "Airbnb",
-				Title:     c.Title,
-				RawPrice:  c.Price,
-				Location:  loc,
-				RawRating: c.Rating,
-				URL:       c.URL,
-				ScrapedAt: time.Now(),
-			})
+		listings = cardsToListings(cards, sectionName, time.Now())
+		for _, l := range listings {
+			l.PageHash = pageHash
 		}
 
 		// Next page link
 		var next string
-		_ = chromedp.Run(ctx, chromedp.Evaluate(`
-			(function() {
-				var n = document.querySelector('a[aria-label="Next"]') ||
-				        document.querySelector('[data-testid="pagination-next-btn"]') ||
-				        document.querySelector('a[href*="items_offset"]');
-				return n ? n.href : '';
-			})()
-		`, &next))
+		_ = chromedp.Run(ctx, chromedp.Evaluate(nextPageJS, &next))
 		nextURL = next
 		return nil
-	}, s.logger)
+	}, log)
 
 	return listings, nextURL, err
 }
 
-// enrichDetail fetches the listing detail page to grab description
-func (s *AirbnbScraper) enrichDetail(ctx context.Context, listing *models.RawListing) {
+// enrichDetail fetches the listing detail page to grab description. A
+// failure leaves the listing as it is.
+func (s *AirbnbScraper) enrichDetail(ctx context.Context, log *utils.Logger, listing *models.RawListing) {
+	if err := s.FetchDetail(ctx, listing); err != nil {
+		log.With("url", listing.URL).Warn("  Enrich failed for '%s': %v", listing.Title, err)
+	}
+}
+
+// FetchDetail loads a listing's detail page and sets its description and
+// detail page hash
+func (s *AirbnbScraper) FetchDetail(ctx context.Context, listing *models.RawListing) error {
 	if listing.URL == "" {
-		return
+		return nil
 	}
-	s.logger.Debug("  Enriching: %s", listing.Title)
+	s.logger.With("url", listing.URL).Debug("  Enriching: %s", listing.Title)
 
 	var desc string
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(listing.URL),
-		chromedp.Sleep(3*time.Second),
-		chromedp.Evaluate(`
-			(function() {
-				var el =
-					document.querySelector('[data-section-id="DESCRIPTION_DEFAULT"] span') ||
-					document.querySelector('[data-section-id="OVERVIEW_DEFAULT"] h1') ||
-					document.querySelector('h1');
-				return el ? el.innerText.trim() : '';
-			})()
-		`, &desc),
-	)
+	err := s.navigate(ctx, metrics.PageDetail, listing.URL)
+	if err == nil {
+		err = chromedp.Run(ctx,
+			chromedp.Sleep(3*time.Second),
+			chromedp.Evaluate(descriptionJS, &desc),
+		)
+	}
 	if err != nil {
-		s.logger.Warn("  Enrich failed for '%s': %v", listing.Title, err)
-		return
+		return fmt.Errorf("detail page failed: %w", err)
 	}
+	listing.DetailHash = s.archivePage(ctx, storage.ArchiveEntry{
+		Kind: storage.ArchiveKindDetail,
+		URL:  listing.URL,
+	})
 	if desc != "" && !strings.EqualFold(strings.TrimSpace(desc), strings.TrimSpace(listing.Title)) {
 		listing.Description = desc
 	}
+	return nil
+}
+
+// archivePage stores the currently loaded document in the HTML archive and
+// returns its content hash. Archiving is best-effort and never fails a scrape.
+func (s *AirbnbScraper) archivePage(ctx context.Context, entry storage.ArchiveEntry) string {
+	if s.archive == nil {
+		return ""
+	}
+	var body string
+	if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &body, chromedp.ByQuery)); err != nil {
+		s.logger.Warn("  Could not capture page for archive (%s): %v", entry.URL, err)
+		return ""
+	}
+	hash, err := s.archive.Put([]byte(body), entry)
+	if err != nil {
+		s.logger.Warn("  Could not archive page (%s): %v", entry.URL, err)
+		return ""
+	}
+	return hash
+}
+
+// navigate loads a page of the given kind and records how long it took
+func (s *AirbnbScraper) navigate(ctx context.Context, kind, pageURL string) error {
+	start := time.Now()
+	err := chromedp.Run(ctx, chromedp.Navigate(pageURL))
+	s.metrics.PageFetched(kind, time.Since(start), err)
+	return err
+}
+
+// wait waits for the rate limiter and records how long it took
+func (s *AirbnbScraper) wait() {
+	s.metrics.RateLimitWait(s.rateLimiter.Wait())
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"airbnb-scraper/api"
+	"airbnb-scraper/config"
+	"airbnb-scraper/jobs"
+	"airbnb-scraper/metrics"
+	"airbnb-scraper/models"
+	"airbnb-scraper/services"
+	"airbnb-scraper/storage"
+	"airbnb-scraper/utils"
+)
+
+// Listing store backends of the serve command
+const (
+	storePostgres = "postgres"
+	storeMemory   = "memory"
+)
+
+// runServe serves the query API over the clean store and the metrics, and
+// with -jobs the job control API, until interrupted. A running job is
+// cancelled on shutdown.
+func runServe(cfg *config.Config, logger *utils.Logger, args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", cfg.ServeAddr, "address to listen on")
+	backend := fs.String("store", cfg.ServeStore, "listing store: postgres, or memory to serve -history re-cleaned in memory")
+	history := fs.String("history", cfg.RawJSONLPath, "raw CSV or JSONL history loaded by the memory store")
+	enableJobs := fs.Bool("jobs", cfg.ServeJobs, "also serve the job control endpoints that start scrapes")
+	_ = fs.Parse(args)
+
+	store, err := openListingStore(cfg, logger, *backend, *history)
+	if err != nil {
+		logger.Error("Cannot open listing store: %v", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	m := metrics.New()
+	server := api.NewServer(store, services.NewInsightService(cfg, logger), logger)
+	server.EnableMetrics(m.Registry())
+	if *enableJobs {
+		manager := jobs.NewManager(cfg, logger)
+		manager.SetMetrics(m)
+		server.EnableJobs(manager)
+		defer manager.Stop()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("Serving the %s listing store on %s", *backend, *addr)
+	if err := serveHTTP(ctx, *addr, server.Handler()); err != nil {
+		logger.Error("Server failed: %v", err)
+		os.Exit(1)
+	}
+	logger.Info("Server stopped")
+}
+
+// serveHTTP listens on addr until ctx is done, then shuts down gracefully
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) error {
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = httpServer.Shutdown(shutdownCtx)
+	}()
+
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// openListingStore opens PostgreSQL, or re-cleans every run in the raw
+// history into a MemoryStore
+func openListingStore(cfg *config.Config, logger *utils.Logger, backend, history string) (storage.ListingStore, error) {
+	switch backend {
+	case storePostgres:
+		pgWriter, err := storage.NewPostgresWriter(cfg.DatabaseURL, logger)
+		if err != nil {
+			return nil, err
+		}
+		return pgWriter, nil
+	case storeMemory:
+		rawListings, err := storage.ReadRawListings(history)
+		if err != nil {
+			return nil, err
+		}
+		cleaner, err := services.NewDataCleaner(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		insightSvc := services.NewInsightService(cfg, logger)
+
+		var listings []*models.Listing
+		runs := groupByRun(rawListings)
+		for _, raw := range runs {
+			cleaned := cleaner.Clean(raw).Listings
+			insightSvc.FlagOutliers(cleaned)
+			insightSvc.ScoreValue(cleaned)
+			listings = append(listings, cleaned...)
+		}
+		logger.Info("Loaded %d clean listings from %d runs in %s", len(listings), len(runs), history)
+		return storage.NewMemoryStore(listings), nil
+	default:
+		return nil, fmt.Errorf("unknown store %q (use postgres or memory)", backend)
+	}
+}
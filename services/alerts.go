@@ -0,0 +1,227 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"airbnb-scraper/models"
+	"airbnb-scraper/utils"
+)
+
+// AlertRule is one watch from the alert rules file. Every condition that is
+// set must hold for a listing to match; unset conditions are ignored.
+type AlertRule struct {
+	Name         string   `json:"name"`
+	Location     string   `json:"location,omitempty"`      // city, raw location, country or country code
+	PropertyType string   `json:"property_type,omitempty"` // e.g. "apartment"
+	PriceBelow   float64  `json:"price_below,omitempty"`   // nightly, reporting currency
+	RatingAbove  float64  `json:"rating_above,omitempty"`
+	PriceDropPct float64  `json:"price_drop_pct,omitempty"` // drop since the listing's last run
+	NewListing   bool     `json:"new_listing,omitempty"`    // not seen in any earlier run in the trend window
+	Notify       []string `json:"notify"`                   // notifier names
+}
+
+// AlertHistory is what earlier runs know about listings, built from snapshots
+type AlertHistory struct {
+	lastPrice map[string]float64 // latest earlier priced snapshot per listing
+	seen      map[string]bool
+}
+
+// NewAlertHistory indexes the snapshots of every run except currentRunID
+func NewAlertHistory(snapshots []*models.Snapshot, currentRunID string) *AlertHistory {
+	h := &AlertHistory{lastPrice: make(map[string]float64), seen: make(map[string]bool)}
+	// Snapshots come oldest first, so later ones overwrite earlier prices
+	for _, s := range snapshots {
+		if s.RunID == currentRunID {
+			continue
+		}
+		h.seen[s.ListingID] = true
+		if s.Price > 0 {
+			h.lastPrice[s.ListingID] = s.Price
+		}
+	}
+	return h
+}
+
+// AlertService evaluates alert rules after a run and hands matches to notifiers
+type AlertService struct {
+	rules     []*AlertRule
+	notifiers map[string]Notifier
+	currency  string
+	logger    *utils.Logger
+}
+
+// LoadAlertService reads a JSON file of the form {"notifiers": [...], "rules": [...]}
+// and checks that rule and notifier names are unique and that every rule
+// refers to a defined notifier
+func LoadAlertService(path, currency string, logger *utils.Logger) (*AlertService, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert rules: %w", err)
+	}
+	var file struct {
+		Notifiers []*NotifierConfig `json:"notifiers"`
+		Rules     []*AlertRule      `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules %s: %w", path, err)
+	}
+
+	s := &AlertService{rules: file.Rules, notifiers: make(map[string]Notifier), currency: currency, logger: logger}
+	for i, nc := range file.Notifiers {
+		n, err := NewNotifier(nc)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %d (%s) in %s: %w", i+1, nc.Name, path, err)
+		}
+		if _, dup := s.notifiers[nc.Name]; dup {
+			return nil, fmt.Errorf("notifier %q is defined twice in %s", nc.Name, path)
+		}
+		s.notifiers[nc.Name] = n
+	}
+	names := make(map[string]bool)
+	for i, r := range file.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("alert rule %d in %s: name is required", i+1, path)
+		}
+		// Notify routes alerts by rule name
+		if names[r.Name] {
+			return nil, fmt.Errorf("alert rule %q is defined twice in %s", r.Name, path)
+		}
+		names[r.Name] = true
+		if r.Location == "" && r.PropertyType == "" && r.PriceBelow <= 0 && r.RatingAbove <= 0 &&
+			r.PriceDropPct <= 0 && !r.NewListing {
+			return nil, fmt.Errorf("alert rule %q in %s: needs at least one condition", r.Name, path)
+		}
+		if len(r.Notify) == 0 {
+			return nil, fmt.Errorf("alert rule %q in %s: notify is required", r.Name, path)
+		}
+		for _, name := range r.Notify {
+			if _, ok := s.notifiers[name]; !ok {
+				return nil, fmt.Errorf("alert rule %q in %s: unknown notifier %q", r.Name, path, name)
+			}
+		}
+	}
+	return s, nil
+}
+
+// Evaluate returns one alert per rule and matching listing. Listings in a
+// near-duplicate cluster only alert through their canonical member.
+func (s *AlertService) Evaluate(listings []*models.Listing, history *AlertHistory) []*models.Alert {
+	now := time.Now()
+	var alerts []*models.Alert
+	for _, r := range s.rules {
+		for _, l := range listings {
+			if !l.IsCanonical {
+				continue
+			}
+			reasons, ok := r.match(l, history)
+			if !ok {
+				continue
+			}
+			alerts = append(alerts, &models.Alert{
+				Rule:          r.Name,
+				RunID:         l.RunID,
+				ListingID:     l.ListingID,
+				Title:         l.Title,
+				Location:      locationKey(l),
+				URL:           l.URL,
+				Currency:      s.currency,
+				Price:         l.Price,
+				PreviousPrice: history.lastPrice[l.ListingID],
+				Rating:        l.Rating,
+				Reasons:       reasons,
+				TriggeredAt:   now,
+			})
+		}
+	}
+	return alerts
+}
+
+// Notify sends each notifier the alerts of the rules routed to it, in one
+// batch per notifier. A failing notifier is logged and does not stop the others.
+func (s *AlertService) Notify(alerts []*models.Alert) {
+	if len(alerts) == 0 {
+		return
+	}
+	routes := make(map[string][]string, len(s.rules))
+	for _, r := range s.rules {
+		routes[r.Name] = r.Notify
+	}
+	batches := make(map[string][]*models.Alert)
+	var order []string
+	for _, a := range alerts {
+		for _, name := range routes[a.Rule] {
+			if _, ok := batches[name]; !ok {
+				order = append(order, name)
+			}
+			batches[name] = append(batches[name], a)
+		}
+	}
+	for _, name := range order {
+		if err := s.notifiers[name].Notify(batches[name]); err != nil {
+			s.logger.With("notifier", name).Error("Alert notifier %s failed: %v", name, err)
+			continue
+		}
+		s.logger.Info("Sent %d alerts to %s", len(batches[name]), name)
+	}
+}
+
+// match checks every condition of the rule and describes the ones that matched
+func (r *AlertRule) match(l *models.Listing, h *AlertHistory) ([]string, bool) {
+	var reasons []string
+	if r.Location != "" {
+		if !matchesLocation(l, r.Location) {
+			return nil, false
+		}
+		reasons = append(reasons, "in "+r.Location)
+	}
+	if r.PropertyType != "" {
+		if string(l.PropertyType) != r.PropertyType {
+			return nil, false
+		}
+		reasons = append(reasons, l.PropertyType.Label())
+	}
+	if r.PriceBelow > 0 {
+		if l.Price <= 0 || l.Price >= r.PriceBelow {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("price %.2f < %.2f", l.Price, r.PriceBelow))
+	}
+	if r.RatingAbove > 0 {
+		if l.Rating <= r.RatingAbove {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("rating %.2f > %.2f", l.Rating, r.RatingAbove))
+	}
+	if r.PriceDropPct > 0 {
+		prev := h.lastPrice[l.ListingID]
+		if prev <= 0 || l.Price <= 0 {
+			return nil, false
+		}
+		drop := (prev - l.Price) / prev * 100
+		if drop < r.PriceDropPct {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("price dropped %.1f%% from %.2f", drop, prev))
+	}
+	if r.NewListing {
+		// Without history every listing would be new
+		if len(h.seen) == 0 || h.seen[l.ListingID] {
+			return nil, false
+		}
+		reasons = append(reasons, "new listing")
+	}
+	return reasons, true
+}
+
+func matchesLocation(l *models.Listing, want string) bool {
+	for _, v := range []string{l.City, l.Location, l.Country, l.CountryCode, l.Neighborhood} {
+		if v != "" && strings.EqualFold(v, want) {
+			return true
+		}
+	}
+	return false
+}
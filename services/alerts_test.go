@@ -0,0 +1,296 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"airbnb-scraper/models"
+	"airbnb-scraper/utils"
+)
+
+// writeAlertRules writes an alert rules file to a temp dir and returns its path
+func writeAlertRules(t *testing.T, rules string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "alert_rules.json")
+	if err := os.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAlertServiceRejectsDuplicateRuleNames(t *testing.T) {
+	path := writeAlertRules(t, `{
+		"notifiers": [{ "name": "log", "type": "file", "path": "alerts.jsonl" }],
+		"rules": [
+			{ "name": "cheap", "price_below": 40, "notify": ["log"] },
+			{ "name": "cheap", "rating_above": 4.8, "notify": ["log"] }
+		]
+	}`)
+	_, err := LoadAlertService(path, "USD", utils.NewLogger())
+	if err == nil || !strings.Contains(err.Error(), `"cheap" is defined twice`) {
+		t.Fatalf("expected a duplicate rule error, got %v", err)
+	}
+}
+
+func TestLoadAlertServiceValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  string
+	}{
+		{"duplicate notifier",
+			`{"notifiers": [{"name": "log", "type": "file", "path": "a"}, {"name": "log", "type": "file", "path": "b"}], "rules": []}`,
+			`notifier "log" is defined twice`},
+		{"unknown notifier",
+			`{"notifiers": [], "rules": [{"name": "cheap", "price_below": 40, "notify": ["log"]}]}`,
+			`unknown notifier "log"`},
+		{"no condition",
+			`{"notifiers": [{"name": "log", "type": "file", "path": "a"}], "rules": [{"name": "all", "notify": ["log"]}]}`,
+			"needs at least one condition"},
+		{"no name",
+			`{"notifiers": [{"name": "log", "type": "file", "path": "a"}], "rules": [{"price_below": 40, "notify": ["log"]}]}`,
+			"name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadAlertService(writeAlertRules(t, tt.rules), "USD", utils.NewLogger())
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// webhookReceiver records the alert batches POSTed to it
+type webhookReceiver struct {
+	mu      sync.Mutex
+	batches [][]*models.Alert
+	headers []http.Header
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Alerts []*models.Alert `json:"alerts"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&body) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	rcv.mu.Lock()
+	rcv.batches = append(rcv.batches, body.Alerts)
+	rcv.headers = append(rcv.headers, r.Header.Clone())
+	rcv.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	rcv := &webhookReceiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	n, err := NewNotifier(&NotifierConfig{Name: "team", Type: NotifierWebhook, URL: srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts := []*models.Alert{
+		{Rule: "cheap", ListingID: "1", Title: "Condo in Bang Na", Price: 35, Currency: "USD"},
+		{Rule: "cheap", ListingID: "2", Title: "Room in Silom", Price: 28, Currency: "USD"},
+	}
+	if err := n.Notify(alerts); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if len(rcv.batches) != 1 || len(rcv.batches[0]) != 2 {
+		t.Fatalf("expected one batch of 2 alerts, got %v", rcv.batches)
+	}
+	if got := rcv.batches[0][1]; got.ListingID != "2" || got.Price != 28 {
+		t.Errorf("unexpected alert %+v", got)
+	}
+	if got := rcv.headers[0].Get("Authorization"); got != "Bearer secret" {
+		t.Errorf("Authorization header = %q", got)
+	}
+	if got := rcv.headers[0].Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestWebhookNotifierFailsOnErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	n, err := NewNotifier(&NotifierConfig{Name: "team", Type: NotifierWebhook, URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify([]*models.Alert{{Rule: "cheap", ListingID: "1"}})
+	if err == nil || !strings.Contains(err.Error(), "502") {
+		t.Fatalf("expected a 502 error, got %v", err)
+	}
+}
+
+// smtpStandIn is a local SMTP server that accepts every message without
+// authentication and records the envelope and data of each
+type smtpStandIn struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []smtpMessage
+}
+
+type smtpMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func startSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStandIn{ln: ln}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost SMTP stand-in")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = smtpMessage{from: strings.Trim(line[len("MAIL FROM:"):], "<> ")}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.to = append(msg.to, strings.Trim(line[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			msg.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	srv := startSMTPStandIn(t)
+	addr := srv.ln.Addr().(*net.TCPAddr)
+
+	n, err := NewNotifier(&NotifierConfig{Name: "mail", Type: NotifierSMTP, Host: addr.IP.String(), Port: addr.Port,
+		From: "alerts@example.com", To: []string{"team@example.com", "ops@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts := []*models.Alert{{Rule: "cheap-bangkok", Title: "Condo in Bang Na", Location: "Bangkok",
+		URL: "https://www.airbnb.com/rooms/1", Price: 35, Currency: "USD", Reasons: []string{"in Bangkok", "price 35.00 < 40.00"}}}
+	if err := n.Notify(alerts); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(srv.messages))
+	}
+	msg := srv.messages[0]
+	if msg.from != "alerts@example.com" || strings.Join(msg.to, ",") != "team@example.com,ops@example.com" {
+		t.Errorf("unexpected envelope from %q to %v", msg.from, msg.to)
+	}
+	for _, want := range []string{"Subject: 1 Airbnb listing alerts", "[cheap-bangkok] Condo in Bang Na (Bangkok)",
+		"price 35.00 < 40.00", "https://www.airbnb.com/rooms/1"} {
+		if !strings.Contains(msg.data, want) {
+			t.Errorf("message does not contain %q:\n%s", want, msg.data)
+		}
+	}
+}
+
+func TestAlertServiceNotifyRoutesByRule(t *testing.T) {
+	team, all := &webhookReceiver{}, &webhookReceiver{}
+	teamSrv, allSrv := httptest.NewServer(team), httptest.NewServer(all)
+	defer teamSrv.Close()
+	defer allSrv.Close()
+
+	path := writeAlertRules(t, fmt.Sprintf(`{
+		"notifiers": [
+			{ "name": "team", "type": "webhook", "url": %q },
+			{ "name": "all",  "type": "webhook", "url": %q }
+		],
+		"rules": [
+			{ "name": "cheap",     "price_below": 40,   "notify": ["all"] },
+			{ "name": "top-rated", "rating_above": 4.8, "notify": ["team", "all"] }
+		]
+	}`, teamSrv.URL, allSrv.URL))
+	s, err := LoadAlertService(path, "USD", utils.NewLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listings := []*models.Listing{
+		{ListingID: "1", Title: "Cheap room", Price: 30, Rating: 4.5, IsCanonical: true},
+		{ListingID: "2", Title: "Great flat", Price: 90, Rating: 4.9, IsCanonical: true},
+	}
+	alerts := s.Evaluate(listings, NewAlertHistory(nil, "run"))
+	if len(alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %d", len(alerts))
+	}
+	s.Notify(alerts)
+
+	if len(team.batches) != 1 || len(team.batches[0]) != 1 || team.batches[0][0].Rule != "top-rated" {
+		t.Errorf("team got %v, want only the top-rated alert", team.batches)
+	}
+	if len(all.batches) != 1 || len(all.batches[0]) != 2 {
+		t.Errorf("all got %v, want both alerts in one batch", all.batches)
+	}
+}
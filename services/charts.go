@@ -0,0 +1,206 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"airbnb-scraper/models"
+
+	"golang.org/x/term"
+)
+
+// Terminal width limits: narrower cannot fit a label and a bar, wider makes
+// bars hard to compare
+const (
+	defaultTerminalWidth = 80
+	minTerminalWidth     = 40
+	maxTerminalWidth     = 160
+)
+
+// Scatter grid size
+const (
+	scatterRows    = 8
+	maxScatterCols = 60
+)
+
+// partialBlocks draws the fraction of a bar's last cell in eighths
+var partialBlocks = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// densityShades draws scatter cells from empty to most crowded
+var densityShades = []string{" ", "·", "░", "▒", "▓", "█"}
+
+// terminalWidth is the width charts written to w are sized to: that of the
+// terminal when w is one, else COLUMNS (which shells rarely export), else the
+// default. A report rendered to a file is never sized to the terminal.
+func terminalWidth(w io.Writer) int {
+	width := 0
+	if f, ok := w.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		width, _, _ = term.GetSize(int(f.Fd()))
+	}
+	if width <= 0 {
+		width, _ = strconv.Atoi(os.Getenv("COLUMNS"))
+	}
+	if width <= 0 {
+		return defaultTerminalWidth
+	}
+	if width < minTerminalWidth {
+		return minTerminalWidth
+	}
+	if width > maxTerminalWidth {
+		return maxTerminalWidth
+	}
+	return width
+}
+
+// printDistributions prints the price histograms per location, the rating
+// histogram and the price-vs-rating scatter
+func printDistributions(w io.Writer, report *models.InsightReport, thin string) {
+	width := terminalWidth(w)
+	currency := report.Currency
+	if currency == "" {
+		currency = "USD"
+	}
+	for _, h := range report.PriceHistograms {
+		fmt.Fprintf(w, "\n PRICE DISTRIBUTION: %s (nightly, %s, %d listings)\n%s\n", h.Key, currency, h.Total, thin)
+		printHistogram(w, h, width)
+	}
+	if h := report.RatingHistogram; h != nil {
+		fmt.Fprintf(w, "\n RATING DISTRIBUTION (%d rated listings)\n%s\n", h.Total, thin)
+		printHistogram(w, h, width)
+	}
+	if s := report.PriceRating; s != nil {
+		fmt.Fprintf(w, "\n PRICE VS RATING (%d listings)\n%s\n", len(s.Points), thin)
+		printScatter(w, s, currency, width)
+	}
+}
+
+// printHistogram prints one bar per bucket, scaled so the fullest bucket
+// spans the available width
+func printHistogram(w io.Writer, h *models.Histogram, width int) {
+	decimals := bucketDecimals(h.Width)
+	labels := make([]string, len(h.Counts))
+	labelW, maxCount := 0, 0
+	for i, c := range h.Counts {
+		lo := h.Start + float64(i)*h.Width
+		labels[i] = fmt.Sprintf("%.*f–%.*f", decimals, lo, decimals, lo+h.Width)
+		if n := len([]rune(labels[i])); n > labelW {
+			labelW = n
+		}
+		if c > maxCount {
+			maxCount = c
+		}
+	}
+	countW := len(strconv.Itoa(maxCount))
+	barW := width - 2 - labelW - 1 - 1 - countW
+	if barW < 1 {
+		barW = 1
+	}
+	for i, c := range h.Counts {
+		pad := strings.Repeat(" ", labelW-len([]rune(labels[i])))
+		fmt.Fprintf(w, "  %s%s %s %d\n", labels[i], pad, bar(float64(c)/float64(maxCount)*float64(barW)), c)
+	}
+}
+
+// bar draws a bar of the given length in cells, with eighth-cell precision
+func bar(cells float64) string {
+	full := int(cells)
+	eighths := int((cells - float64(full)) * 8)
+	return strings.Repeat("█", full) + partialBlocks[eighths]
+}
+
+// bucketDecimals is how many decimals bucket edges need to be told apart
+func bucketDecimals(width float64) int {
+	if width >= 1 {
+		return 0
+	}
+	return int(math.Ceil(-math.Log10(width)))
+}
+
+// printScatter prints a density grid of rating against nightly price and the
+// rank correlation between them
+func printScatter(w io.Writer, s *models.PriceRatingScatter, currency string, width int) {
+	minP, maxP := math.Inf(1), math.Inf(-1)
+	minR, maxR := math.Inf(1), math.Inf(-1)
+	for _, p := range s.Points {
+		minP, maxP = math.Min(minP, p.Price), math.Max(maxP, p.Price)
+		minR, maxR = math.Min(minR, p.Rating), math.Max(maxR, p.Rating)
+	}
+
+	cols := width - 10 // rating labels and axis
+	if cols > maxScatterCols {
+		cols = maxScatterCols
+	}
+	grid := make([][]int, scatterRows)
+	for i := range grid {
+		grid[i] = make([]int, cols)
+	}
+	cell := func(v, lo, hi float64, n int) int {
+		if hi == lo {
+			return n / 2
+		}
+		i := int((v - lo) / (hi - lo) * float64(n))
+		if i >= n {
+			i = n - 1
+		}
+		return i
+	}
+	maxCell := 0
+	for _, p := range s.Points {
+		row := scatterRows - 1 - cell(p.Rating, minR, maxR, scatterRows) // highest rating on top
+		col := cell(p.Price, minP, maxP, cols)
+		grid[row][col]++
+		if grid[row][col] > maxCell {
+			maxCell = grid[row][col]
+		}
+	}
+
+	for i, row := range grid {
+		label := "    "
+		switch i {
+		case 0:
+			label = fmt.Sprintf("%.2f", maxR)
+		case scatterRows - 1:
+			label = fmt.Sprintf("%.2f", minR)
+		}
+		var line strings.Builder
+		for _, c := range row {
+			level := 0
+			if c > 0 {
+				level = int(math.Ceil(float64(c) / float64(maxCell) * float64(len(densityShades)-1)))
+			}
+			line.WriteString(densityShades[level])
+		}
+		fmt.Fprintf(w, "  %s ┤%s\n", label, line.String())
+	}
+	fmt.Fprintf(w, "       └%s\n", strings.Repeat("─", cols))
+	lo, hi := formatMoney(minP, currency), formatMoney(maxP, currency)
+	gap := cols - len([]rune(lo)) - len([]rune(hi))
+	if gap < 1 {
+		gap = 1
+	}
+	fmt.Fprintf(w, "        %s%s%s\n", lo, strings.Repeat(" ", gap), hi)
+	fmt.Fprintf(w, "  Spearman correlation %+.2f: %s\n", s.Correlation, describeCorrelation(s.Correlation))
+}
+
+// describeCorrelation puts a rank correlation between price and rating into words
+func describeCorrelation(rho float64) string {
+	strength := ""
+	switch a := math.Abs(rho); {
+	case a < 0.1:
+		return "no clear relation between price and rating"
+	case a < 0.3:
+		strength = "slightly"
+	case a < 0.5:
+		strength = "moderately"
+	default:
+		strength = "strongly"
+	}
+	if rho > 0 {
+		return "pricier listings are " + strength + " better rated"
+	}
+	return "pricier listings are " + strength + " worse rated"
+}
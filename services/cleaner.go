@@ -1,126 +1,195 @@
 package services
 
 import (
-	"regexp"
-	"strconv"
+	"fmt"
 	"strings"
 	"time"
 
+	"airbnb-scraper/config"
 	"airbnb-scraper/models"
 	"airbnb-scraper/utils"
 )
 
-var (
-	priceRegex  = regexp.MustCompile(`\$?([\d,]+(?:\.\d{2})?)`)
-	ratingRegex = regexp.MustCompile(`([45]\.\d{1,2}|\d\.\d{1,2})`)
-)
-
 // DataCleaner normalizes raw scraped data into clean Listing records
 type DataCleaner struct {
-	logger *utils.Logger
+	rates             *ExchangeRates
+	reportingCurrency string
+	defaultCurrency   string // assumed when a price shows no currency marker
+	gazetteer         *Gazetteer
+	validator         *Validator
+	duplicates        *DuplicateDetector
+	logger            *utils.Logger
 }
 
-// NewDataCleaner creates a new DataCleaner
-func NewDataCleaner(logger *utils.Logger) *DataCleaner {
-	return &DataCleaner{logger: logger}
+// CleanResult is the output of Clean: listings that passed validation, the
+// rejected records to quarantine, and per-rule counts for the run
+type CleanResult struct {
+	Listings   []*models.Listing
+	Quarantine []*models.QuarantinedListing
+	Stats      ValidationStats
+}
+
+// NewDataCleaner creates a new DataCleaner, loading the exchange rates table,
+// the gazetteer and the validation rules
+func NewDataCleaner(cfg *config.Config, logger *utils.Logger) (*DataCleaner, error) {
+	rates, err := LoadExchangeRates(cfg.ExchangeRatesFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range []string{cfg.ReportingCurrency, cfg.DefaultCurrency} {
+		if _, ok := rates.Rates[code]; !ok {
+			return nil, fmt.Errorf("exchange rates %s have no rate for %s", cfg.ExchangeRatesFile, code)
+		}
+	}
+	gazetteer, err := LoadGazetteer(cfg.GazetteerFile)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := LoadValidator(cfg.ValidationRulesFile)
+	if err != nil {
+		return nil, err
+	}
+	return &DataCleaner{
+		rates:             rates,
+		reportingCurrency: cfg.ReportingCurrency,
+		defaultCurrency:   cfg.DefaultCurrency,
+		gazetteer:         gazetteer,
+		validator:         validator,
+		duplicates:        NewDuplicateDetector(cfg, logger),
+		logger:            logger,
+	}, nil
 }
 
-// Clean converts a slice of RawListings to clean Listings
-func (c *DataCleaner) Clean(raw []*models.RawListing) []*models.Listing {
+// Clean converts a slice of RawListings to clean Listings and validates them.
+// Rejected records are returned for quarantine instead of being dropped.
+func (c *DataCleaner) Clean(raw []*models.RawListing) *CleanResult {
 	seen := make(map[string]bool)
-	var cleaned []*models.Listing
+	result := &CleanResult{Stats: ValidationStats{RuleHits: make(map[string]int)}}
 
 	for _, r := range raw {
-		// Skip if title or URL empty
-		if strings.TrimSpace(r.Title) == "" {
-			c.logger.Debug("Skipping listing with empty title")
-			continue
+		// Deduplicate by listing ID, falling back to the normalized URL
+		listingID, canonicalURL := utils.CanonicalizeListingURL(r.URL)
+		key := listingID
+		if key == "" {
+			key = canonicalURL
 		}
-
-		// Deduplicate by URL
-		key := strings.TrimSpace(r.URL)
 		if key == "" {
 			key = strings.TrimSpace(r.Title) + "|" + strings.TrimSpace(r.Location)
 		}
 		if seen[key] {
-			c.logger.Debug("Skipping duplicate: %s", r.Title)
+			c.logger.With("url", r.URL).Debug("Skipping duplicate: %s", r.Title)
+			result.Stats.Duplicates++
 			continue
 		}
 		seen[key] = true
 
-		price := parsePrice(r.RawPrice)
-		rating := parseRating(r.RawRating)
+		breakdown := parsePrice(r.RawPrice)
+		currency := breakdown.Currency
+		if currency == "" {
+			currency = c.defaultCurrency
+		}
+		price, err := c.rates.Convert(breakdown.Nightly, currency, c.reportingCurrency)
+		if err != nil {
+			c.logger.With("url", r.URL).Warn("Cannot convert price of '%s' (%s): %v", r.Title, r.RawPrice, err)
+			price = 0
+		}
+		ratingInfo := parseRating(r.RawRating)
+		propertyType, neighborhood := parsePropertyTitle(r.Title)
+		beds, bedrooms := parseCapacity(r.Subtitle)
 
 		listing := &models.Listing{
-			Platform:    strings.TrimSpace(r.Platform),
-			Title:       strings.TrimSpace(r.Title),
-			Price:       price,
-			Location:    cleanLocation(r.Location),
-			Rating:      rating,
-			URL:         strings.TrimSpace(r.URL),
-			Description: strings.TrimSpace(r.Description),
-			ScrapedAt:   r.ScrapedAt,
+			ListingID:        listingID,
+			RunID:            r.RunID,
+			Platform:         strings.TrimSpace(r.Platform),
+			Title:            strings.TrimSpace(r.Title),
+			Price:            price,
+			Currency:         currency,
+			LocalPrice:       breakdown.Nightly,
+			PreDiscountPrice: breakdown.PreDiscountPrice,
+			DiscountPct:      breakdown.DiscountPct,
+			TotalPrice:       breakdown.Total,
+			Nights:           breakdown.Nights,
+			TaxesIncluded:    breakdown.TaxesIncluded,
+			PropertyType:     propertyType,
+			Beds:             beds,
+			Bedrooms:         bedrooms,
+			Location:         cleanLocation(r.Location),
+			Rating:           ratingInfo.Rating,
+			ReviewCount:      ratingInfo.ReviewCount,
+			IsNew:            ratingInfo.IsNew,
+			IsSuperhost:      r.Superhost,
+			URL:              canonicalURL,
+			PhotoURL:         strings.TrimSpace(r.PhotoURL),
+			PhotoHash:        photoHash(r.PhotoURL),
+			Description:      strings.TrimSpace(r.Description),
+			ScrapedAt:        r.ScrapedAt,
 		}
 		if listing.ScrapedAt.IsZero() {
 			listing.ScrapedAt = time.Now()
 		}
+		c.resolveLocation(listing, neighborhood, r)
+		listing.DescriptionClean = redactPII(normalizeDescription(listing.Description))
+		listing.DescriptionLang = detectLanguage(listing.DescriptionClean)
+
+		status, issues := c.validator.Validate(listing)
+		result.Stats.record(status, issues)
+		if status == models.ValidationRejected {
+			c.logger.With("listing_id", listing.ListingID, "code", issues[0].Code).Debug("Rejecting '%s': %s", listing.Title, issues[0].Message)
+			result.Quarantine = append(result.Quarantine, &models.QuarantinedListing{
+				RunID:         r.RunID,
+				ListingID:     listingID,
+				Raw:           r,
+				Issues:        issues,
+				QuarantinedAt: time.Now(),
+			})
+			continue
+		}
+		listing.ValidationStatus = status
+		for _, is := range issues {
+			listing.ValidationCodes = append(listing.ValidationCodes, is.Code)
+		}
 
-		cleaned = append(cleaned, listing)
-	}
-
-	c.logger.Info("Cleaned %d listings from %d raw records", len(cleaned), len(raw))
-	return cleaned
-}
-
-// parsePrice extracts a per-night price number from a raw string like "$71 for 2 nights"
-func parsePrice(raw string) float64 {
-	if raw == "" {
-		return 0
-	}
-	// Remove commas
-	cleaned := strings.ReplaceAll(raw, ",", "")
-
-	// Find first number after $
-	matches := priceRegex.FindStringSubmatch(cleaned)
-	if len(matches) < 2 {
-		return 0
-	}
-
-	val, err := strconv.ParseFloat(matches[1], 64)
-	if err != nil {
-		return 0
+		result.Listings = append(result.Listings, listing)
 	}
 
-	// If "for N nights", divide to get per-night
-	nightsRegex := regexp.MustCompile(`for\s+(\d+)\s+night`)
-	if m := nightsRegex.FindStringSubmatch(cleaned); len(m) >= 2 {
-		nights, err := strconv.ParseFloat(m[1], 64)
-		if err == nil && nights > 0 {
-			return val / nights
-		}
-	}
+	c.duplicates.Detect(result.Listings)
 
-	return val
+	c.logger.Info("Cleaned %d listings from %d raw records (%d warned, %d rejected, %d duplicates)",
+		len(result.Listings), len(raw), result.Stats.Warned, result.Stats.Rejected, result.Stats.Duplicates)
+	return result
 }
 
-// parseRating extracts a float rating from strings like "4.82 out of 5 average rating"
-func parseRating(raw string) float64 {
-	if raw == "" {
-		return 0
+// resolveLocation fills the normalized location fields. The section city is
+// the context: a neighborhood name that the gazetteer places in a different
+// city (a second "Chinatown") is kept as raw text instead of moving the listing.
+func (c *DataCleaner) resolveLocation(l *models.Listing, neighborhood string, r *models.RawListing) {
+	sectionCity := cleanLocation(r.Section)
+	if sectionCity == "" {
+		sectionCity = cleanLocation(r.Location)
 	}
-	matches := ratingRegex.FindStringSubmatch(raw)
-	if len(matches) < 2 {
-		return 0
-	}
-	val, err := strconv.ParseFloat(matches[1], 64)
-	if err != nil {
-		return 0
+	l.Neighborhood, l.City = neighborhood, sectionCity
+
+	cityPlace, cityOK := c.gazetteer.Lookup(sectionCity)
+	place, ok := c.gazetteer.Lookup(neighborhood)
+	if ok && cityOK && place.City != cityPlace.City {
+		ok = false
 	}
-	// Sanity: rating should be between 0 and 5
-	if val > 5 {
-		return 0
+	switch {
+	case ok:
+		// "Home in Tokyo" names a city, not a neighborhood
+		l.Neighborhood = place.Neighborhood
+	case cityOK:
+		place = cityPlace
+	default:
+		c.logger.Debug("Location not in gazetteer: %q / %q", neighborhood, sectionCity)
+		return
 	}
-	return val
+	l.City = place.City
+	l.Region = place.Region
+	l.Country = place.Country
+	l.CountryCode = place.CountryCode
+	l.Latitude = place.Latitude
+	l.Longitude = place.Longitude
 }
 
 // cleanLocation normalizes location strings
@@ -131,4 +200,4 @@ func cleanLocation(loc string) string {
 		loc = loc[idx+4:]
 	}
 	return loc
-}
\ No newline at end of file
+}
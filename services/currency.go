@@ -0,0 +1,154 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// currencyToken maps a marker seen in a price string to an ISO 4217 code
+type currencyToken struct {
+	token string
+	code  string
+}
+
+// currencyTokens is ordered so longer, more specific markers win over their
+// suffixes ("US$" and "R$" before "$", "CN¥" before "¥").
+var currencyTokens = []currencyToken{
+	{"US$", "USD"}, {"CA$", "CAD"}, {"C$", "CAD"}, {"A$", "AUD"}, {"AU$", "AUD"},
+	{"NZ$", "NZD"}, {"HK$", "HKD"}, {"S$", "SGD"}, {"NT$", "TWD"}, {"MX$", "MXN"},
+	{"R$", "BRL"}, {"CN¥", "CNY"}, {"JP¥", "JPY"}, {"￥", "CNY"},
+	{"€", "EUR"}, {"£", "GBP"}, {"¥", "JPY"}, {"฿", "THB"}, {"₩", "KRW"},
+	{"₹", "INR"}, {"₫", "VND"}, {"₱", "PHP"}, {"₺", "TRY"}, {"₪", "ILS"},
+	{"$", "USD"},
+}
+
+// alphaCurrencyRegex matches letter-based markers (RM 120, Rp 850.000, THB 1,200)
+// only when they are not part of a longer word
+var alphaCurrencyRegex = regexp.MustCompile(
+	`(?:^|[^A-Za-z])(RM|Rp|CHF|AED|SAR|USD|EUR|GBP|JPY|THB|MYR|IDR|KRW|SGD|INR|VND|PHP|HKD|CNY|AUD|CAD|NZD|BRL|MXN|TWD|TRY|ILS)(?:[^A-Za-z]|$)`)
+
+var alphaCurrencyCodes = map[string]string{"RM": "MYR", "Rp": "IDR"}
+
+// amountRegex matches a number with any mix of thousands/decimal separators
+var amountRegex = regexp.MustCompile(`\d[\d.,'\x{00a0}\x{202f} ]*\d|\d`)
+
+// zeroDecimalCurrencies never show minor units, so any separator in their
+// amounts is a thousands separator ("¥12.000" is twelve thousand)
+var zeroDecimalCurrencies = map[string]bool{
+	"JPY": true, "KRW": true, "IDR": true, "VND": true,
+}
+
+// detectCurrency returns the ISO code of the currency shown in a price string,
+// or "" when no marker is present
+func detectCurrency(raw string) string {
+	if m := alphaCurrencyRegex.FindStringSubmatch(raw); len(m) == 2 {
+		if code, ok := alphaCurrencyCodes[m[1]]; ok {
+			return code
+		}
+		return m[1]
+	}
+	for _, t := range currencyTokens {
+		if strings.Contains(raw, t.token) {
+			return t.code
+		}
+	}
+	return ""
+}
+
+// findAmounts returns every amount in s, parsed with locale-aware separators
+func findAmounts(s, currency string) []float64 {
+	var amounts []float64
+	for _, tok := range amountRegex.FindAllString(s, -1) {
+		if v, ok := parseAmount(tok, currency); ok {
+			amounts = append(amounts, v)
+		}
+	}
+	return amounts
+}
+
+// parseAmount parses a number written in either "1,234.56" or "1.234,56" style.
+// When both separators appear, the last one is the decimal point. A single
+// separator kind is treated as thousands if it repeats or is followed by
+// exactly three digits, and as the decimal point otherwise ("4,5", "12.50").
+func parseAmount(tok, currency string) (float64, bool) {
+	tok = strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "", "'", "").Replace(tok)
+	if tok == "" {
+		return 0, false
+	}
+
+	lastDot := strings.LastIndex(tok, ".")
+	lastComma := strings.LastIndex(tok, ",")
+
+	var normalized string
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastDot > lastComma {
+			normalized = strings.ReplaceAll(tok, ",", "")
+		} else {
+			normalized = strings.ReplaceAll(strings.ReplaceAll(tok, ".", ""), ",", ".")
+		}
+	case lastDot >= 0 || lastComma >= 0:
+		sep, pos := ".", lastDot
+		if lastComma >= 0 {
+			sep, pos = ",", lastComma
+		}
+		digitsAfter := len(tok) - pos - 1
+		if strings.Count(tok, sep) > 1 || digitsAfter == 3 || zeroDecimalCurrencies[currency] {
+			normalized = strings.ReplaceAll(tok, sep, "")
+		} else {
+			normalized = strings.ReplaceAll(tok, sep, ".")
+		}
+	default:
+		normalized = tok
+	}
+
+	val, err := strconv.ParseFloat(normalized, 64)
+	if err != nil {
+		return 0, false
+	}
+	return val, true
+}
+
+// ExchangeRates converts amounts between currencies using a static rates table
+type ExchangeRates struct {
+	Base  string             `json:"base"`
+	AsOf  string             `json:"as_of"`
+	Rates map[string]float64 `json:"rates"` // units of currency per 1 unit of Base
+}
+
+// LoadExchangeRates reads a rates table from a JSON file
+func LoadExchangeRates(path string) (*ExchangeRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exchange rates: %w", err)
+	}
+	var rates ExchangeRates
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, fmt.Errorf("failed to parse exchange rates %s: %w", path, err)
+	}
+	if rates.Base == "" || len(rates.Rates) == 0 {
+		return nil, fmt.Errorf("exchange rates %s must define base and rates", path)
+	}
+	rates.Rates[rates.Base] = 1
+	return &rates, nil
+}
+
+// Convert converts amount from one currency to another via the base currency
+func (r *ExchangeRates) Convert(amount float64, from, to string) (float64, error) {
+	if from == to {
+		return amount, nil
+	}
+	fromRate, ok := r.Rates[from]
+	if !ok || fromRate <= 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", from)
+	}
+	toRate, ok := r.Rates[to]
+	if !ok || toRate <= 0 {
+		return 0, fmt.Errorf("no exchange rate for %s", to)
+	}
+	return amount / fromRate * toRate, nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Placeholders that replace personal details in descriptions
+const (
+	redactedEmail = "[EMAIL]"
+	redactedPhone = "[PHONE]"
+	redactedURL   = "[URL]"
+)
+
+var (
+	urlRegex   = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9-]+(?:\.[a-z0-9-]+)*\.(?:com|net|org|io|co|me|ly|info|biz)(?:/\S*)?\b`)
+	emailRegex = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}\b`)
+	// phoneRegex finds digit runs with phone-style separators; redactPII
+	// then keeps only those with enough digits to be a phone number
+	phoneRegex = regexp.MustCompile(`\+?\(?\d[\d\s().-]{6,}\d`)
+	dateRegex  = regexp.MustCompile(`^\d{4}[-./]\d{1,2}[-./]\d{1,2}$|^\d{1,2}[-./]\d{1,2}[-./]\d{4}$`)
+)
+
+// minPhoneDigits keeps dates, prices and years out of phone redaction
+const minPhoneDigits = 9
+
+// textReplacer maps typographic characters to plain ones and drops invisible ones
+var textReplacer = strings.NewReplacer(
+	"\u00a0", " ", "\u2007", " ", "\u202f", " ", "\u3000", " ",
+	"\u200b", "", "\u200c", "", "\u200d", "", "\u2060", "", "\ufeff", "", "\u00ad", "",
+	"\u2018", "'", "\u2019", "'", "\u201a", "'", "\u201c", `"`, "\u201d", `"`, "\u201e", `"`,
+	"\u2013", "-", "\u2014", "-", "\u2212", "-", "\u2026", "...",
+	"\r\n", "\n", "\r", "\n",
+)
+
+// normalizeDescription cleans a description for storage: unicode spaces,
+// quotes and full-width letters become plain ASCII, invisible characters are
+// dropped, runs of spaces collapse to one and paragraphs are kept with a
+// single blank line between them.
+func normalizeDescription(s string) string {
+	s = textReplacer.Replace(s)
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= '\uff01' && r <= '\uff5e': // full-width ASCII variants
+			return r - 0xfee0
+		case r == '\n' || r == '\t':
+			return r
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, s)
+
+	var paragraphs []string
+	for _, p := range strings.Split(s, "\n\n") {
+		var lines []string
+		for _, line := range strings.Split(p, "\n") {
+			if line = strings.Join(strings.Fields(line), " "); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		if len(lines) > 0 {
+			paragraphs = append(paragraphs, strings.Join(lines, "\n"))
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// redactPII replaces URLs, email addresses and phone numbers with placeholders
+func redactPII(s string) string {
+	s = emailRegex.ReplaceAllString(s, redactedEmail)
+	s = urlRegex.ReplaceAllStringFunc(s, func(m string) string {
+		// keep sentence punctuation that \S+ swallowed
+		trimmed := strings.TrimRight(m, ".,;:!?)")
+		return redactedURL + m[len(trimmed):]
+	})
+	return phoneRegex.ReplaceAllStringFunc(s, func(m string) string {
+		digits := 0
+		for _, r := range m {
+			if r >= '0' && r <= '9' {
+				digits++
+			}
+		}
+		if digits < minPhoneDigits || digits > 15 || dateRegex.MatchString(m) {
+			return m
+		}
+		return redactedPhone
+	})
+}
+
+// scriptLanguages maps scripts used by a single language to its ISO 639-1 code
+var scriptLanguages = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Thai, "th"},
+	{unicode.Hangul, "ko"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Devanagari, "hi"},
+}
+
+// latinStopwords are frequent short words that tell Latin-script languages apart
+var latinStopwords = map[string][]string{
+	"en": {"the", "and", "is", "to", "of", "with", "in", "for", "you", "this", "are", "our", "from", "room", "walk", "near", "or", "we", "your", "at", "it"},
+	"fr": {"le", "la", "les", "et", "est", "des", "une", "avec", "dans", "pour", "vous", "du", "au", "sur", "très"},
+	"es": {"el", "la", "los", "las", "y", "es", "con", "en", "para", "una", "del", "muy", "está", "habitación", "cerca"},
+	"de": {"der", "die", "das", "und", "ist", "mit", "ein", "eine", "für", "sie", "im", "zum", "zur", "nicht", "wohnung"},
+	"it": {"il", "lo", "gli", "della", "di", "con", "una", "per", "sono", "molto", "nel", "alla", "camera", "anche", "questo"},
+	"pt": {"o", "os", "as", "e", "com", "uma", "para", "não", "muito", "do", "da", "em", "são", "quarto", "você"},
+	"nl": {"de", "het", "een", "en", "is", "met", "van", "voor", "je", "op", "niet", "zijn", "bij", "ook", "kamer"},
+	"id": {"dan", "yang", "di", "dengan", "untuk", "ini", "dari", "kamar", "ada", "dekat", "tidak", "kami", "juga", "bisa", "sangat"},
+	"vi": {"và", "của", "là", "có", "không", "được", "trong", "phòng", "với", "cho", "các", "một", "những", "gần", "rất"},
+}
+
+// minLanguageWords is the fewest stopword hits needed to name a Latin-script language
+const minLanguageWords = 2
+
+// detectLanguage guesses the ISO 639-1 language of a text offline. Scripts
+// unique to one language decide directly; Japanese is told from Chinese by
+// kana; Latin-script text is scored by stopwords. Returns "" when unsure.
+func detectLanguage(s string) string {
+	counts := make(map[string]int)
+	var han, kana, latin, letters int
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		default:
+			for _, sl := range scriptLanguages {
+				if unicode.Is(sl.table, r) {
+					counts[sl.code]++
+					break
+				}
+			}
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+
+	// The dominant script wins; Latin is resolved below
+	best, bestCount := "", latin
+	if kana > 0 && han+kana > bestCount {
+		best, bestCount = "ja", han+kana
+	} else if han > bestCount {
+		best, bestCount = "zh", han
+	}
+	for _, sl := range scriptLanguages {
+		if counts[sl.code] > bestCount {
+			best, bestCount = sl.code, counts[sl.code]
+		}
+	}
+	if best != "" {
+		return best
+	}
+	return detectLatinLanguage(s)
+}
+
+// detectLatinLanguage scores Latin-script text against the stopword lists
+func detectLatinLanguage(s string) string {
+	words := make(map[string]int)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		words[w]++
+	}
+
+	best, bestScore := "", 0
+	for _, code := range []string{"en", "fr", "es", "de", "it", "pt", "nl", "id", "vi"} {
+		score := 0
+		for _, w := range latinStopwords[code] {
+			score += words[w]
+		}
+		if score > bestScore {
+			best, bestScore = code, score
+		}
+	}
+	if bestScore < minLanguageWords {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"airbnb-scraper/models"
+)
+
+// Diff output formats
+const (
+	DiffFormatText = "text"
+	DiffFormatJSON = "json"
+	DiffFormatCSV  = "csv"
+)
+
+// changeOrder is the order changes are listed in within a location
+var changeOrder = map[string]int{
+	models.ChangeAdded:       0,
+	models.ChangeRemoved:     1,
+	models.ChangePrice:       2,
+	models.ChangeRating:      3,
+	models.ChangeDescription: 4,
+}
+
+// DiffRuns compares the clean listings of two runs by room ID. Prices are
+// compared in the reporting currency, so a change of exchange rate alone shows
+// up as a price change. Ratings and descriptions missing on either side (e.g.
+// a detail page that failed to load) are not counted as changes.
+func DiffRuns(oldName, newName string, before, after []*models.Listing, currency string) *models.RunDiff {
+	d := &models.RunDiff{Old: oldName, New: newName, Currency: currency, Counts: make(map[string]int)}
+
+	oldByID := indexByListingID(before)
+	newByID := indexByListingID(after)
+
+	byLocation := make(map[string]*models.LocationDiff)
+	add := func(l *models.Listing, c *models.ListingChange) {
+		loc := locationKey(l)
+		ld, ok := byLocation[loc]
+		if !ok {
+			ld = &models.LocationDiff{Location: loc}
+			byLocation[loc] = ld
+		}
+		c.ListingID, c.Title, c.URL = l.ListingID, l.Title, l.URL
+		ld.Changes = append(ld.Changes, c)
+		d.Counts[c.Kind]++
+	}
+
+	for id, n := range newByID {
+		o, ok := oldByID[id]
+		if !ok {
+			add(n, &models.ListingChange{Kind: models.ChangeAdded, NewPrice: n.Price, NewRating: n.Rating})
+			continue
+		}
+		if o.Price > 0 && n.Price > 0 && math.Abs(n.Price-o.Price) >= 0.01 {
+			add(n, &models.ListingChange{
+				Kind:           models.ChangePrice,
+				OldPrice:       o.Price,
+				NewPrice:       n.Price,
+				PriceDelta:     n.Price - o.Price,
+				PriceChangePct: (n.Price - o.Price) / o.Price * 100,
+			})
+		}
+		if o.Rating > 0 && n.Rating > 0 && math.Abs(n.Rating-o.Rating) >= 0.01 {
+			add(n, &models.ListingChange{Kind: models.ChangeRating, OldRating: o.Rating, NewRating: n.Rating})
+		}
+		if o.DescriptionClean != "" && n.DescriptionClean != "" && o.DescriptionClean != n.DescriptionClean {
+			add(n, &models.ListingChange{Kind: models.ChangeDescription})
+		}
+	}
+	for id, o := range oldByID {
+		if _, ok := newByID[id]; !ok {
+			add(o, &models.ListingChange{Kind: models.ChangeRemoved, OldPrice: o.Price, OldRating: o.Rating})
+		}
+	}
+
+	for _, ld := range byLocation {
+		sort.Slice(ld.Changes, func(i, j int) bool {
+			a, b := ld.Changes[i], ld.Changes[j]
+			if a.Kind != b.Kind {
+				return changeOrder[a.Kind] < changeOrder[b.Kind]
+			}
+			if a.Kind == models.ChangePrice && a.PriceChangePct != b.PriceChangePct {
+				return math.Abs(a.PriceChangePct) > math.Abs(b.PriceChangePct)
+			}
+			return a.ListingID < b.ListingID
+		})
+		d.Locations = append(d.Locations, ld)
+	}
+	sort.Slice(d.Locations, func(i, j int) bool { return d.Locations[i].Location < d.Locations[j].Location })
+	return d
+}
+
+// indexByListingID maps listings by room ID. When a room appears twice the
+// later scrape wins.
+func indexByListingID(listings []*models.Listing) map[string]*models.Listing {
+	byID := make(map[string]*models.Listing, len(listings))
+	for _, l := range listings {
+		if l.ListingID == "" {
+			continue
+		}
+		if prev, ok := byID[l.ListingID]; !ok || l.ScrapedAt.After(prev.ScrapedAt) {
+			byID[l.ListingID] = l
+		}
+	}
+	return byID
+}
+
+// WriteDiff renders a run diff in the given format
+func WriteDiff(w io.Writer, d *models.RunDiff, format string) error {
+	switch format {
+	case DiffFormatText:
+		return writeDiffText(w, d)
+	case DiffFormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case DiffFormatCSV:
+		return writeDiffCSV(w, d)
+	default:
+		return fmt.Errorf("unknown diff format %q (available: text, json, csv)", format)
+	}
+}
+
+func writeDiffText(w io.Writer, d *models.RunDiff) error {
+	thin := strings.Repeat("─", 55)
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n RUN DIFF %s → %s\n%s\n", d.Old, d.New, thin)
+	fmt.Fprintf(&b, "  Added         : %d\n", d.Counts[models.ChangeAdded])
+	fmt.Fprintf(&b, "  Removed       : %d\n", d.Counts[models.ChangeRemoved])
+	fmt.Fprintf(&b, "  Price changed : %d\n", d.Counts[models.ChangePrice])
+	fmt.Fprintf(&b, "  Rating changed: %d\n", d.Counts[models.ChangeRating])
+	fmt.Fprintf(&b, "  Description   : %d\n", d.Counts[models.ChangeDescription])
+
+	for _, ld := range d.Locations {
+		fmt.Fprintf(&b, "\n %s (%d)\n%s\n", ld.Location, len(ld.Changes), thin)
+		for _, c := range ld.Changes {
+			title := truncate(c.Title, 35)
+			switch c.Kind {
+			case models.ChangeAdded:
+				fmt.Fprintf(&b, "  + %-35s %s\n", title, formatMoney(c.NewPrice, d.Currency))
+			case models.ChangeRemoved:
+				fmt.Fprintf(&b, "  - %-35s %s\n", title, formatMoney(c.OldPrice, d.Currency))
+			case models.ChangePrice:
+				fmt.Fprintf(&b, "  $ %-35s %s → %s (%+.2f, %+.1f%%)\n", title, formatMoney(c.OldPrice, d.Currency),
+					formatMoney(c.NewPrice, d.Currency), c.PriceDelta, c.PriceChangePct)
+			case models.ChangeRating:
+				fmt.Fprintf(&b, "  * %-35s %.2f → %.2f\n", title, c.OldRating, c.NewRating)
+			case models.ChangeDescription:
+				fmt.Fprintf(&b, "  ~ %-35s description changed\n", title)
+			}
+		}
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeDiffCSV(w io.Writer, d *models.RunDiff) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"location", "change", "listing_id", "title", "old_price", "new_price",
+		"price_delta", "price_change_pct", "old_rating", "new_rating", "url"})
+	num := func(v float64) string {
+		if v == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	for _, ld := range d.Locations {
+		for _, c := range ld.Changes {
+			_ = cw.Write([]string{ld.Location, c.Kind, c.ListingID, c.Title, num(c.OldPrice), num(c.NewPrice),
+				num(c.PriceDelta), num(c.PriceChangePct), num(c.OldRating), num(c.NewRating), c.URL})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"math"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"airbnb-scraper/config"
+	"airbnb-scraper/models"
+	"airbnb-scraper/utils"
+)
+
+// titleStopwords carry no information about which property a title names
+var titleStopwords = map[string]bool{
+	"in": true, "the": true, "a": true, "an": true, "of": true, "and": true,
+	"at": true, "near": true, "with": true, "to": true, "by": true, "on": true,
+	"entire": true, "private": true, "place": true, "stay": true, "unit": true,
+}
+
+// DuplicateDetector groups listings that look like the same property shown
+// under different room IDs, e.g. a host listing one flat twice or a card
+// appearing in two sections with a reworded title.
+type DuplicateDetector struct {
+	minSimilarity  float64 // 0..1
+	priceTolerance float64 // relative, 0..1
+	logger         *utils.Logger
+}
+
+// NewDuplicateDetector creates a DuplicateDetector from the configured thresholds
+func NewDuplicateDetector(cfg *config.Config, logger *utils.Logger) *DuplicateDetector {
+	return &DuplicateDetector{
+		minSimilarity:  float64(cfg.DuplicateSimilarityPct) / 100,
+		priceTolerance: float64(cfg.DuplicatePriceTolerancePct) / 100,
+		logger:         logger,
+	}
+}
+
+// Detect sets ClusterID and IsCanonical on every listing and returns the
+// number of clusters found. Only listings in the same city are compared.
+func (d *DuplicateDetector) Detect(listings []*models.Listing) int {
+	byCity := make(map[string][]int)
+	for i, l := range listings {
+		l.ClusterID, l.IsCanonical = "", true
+		city := strings.ToLower(locationKey(l))
+		byCity[city] = append(byCity[city], i)
+	}
+
+	uf := newUnionFind(len(listings))
+	for _, idx := range byCity {
+		for a := 0; a < len(idx); a++ {
+			for b := a + 1; b < len(idx); b++ {
+				if d.isDuplicate(listings[idx[a]], listings[idx[b]]) {
+					uf.union(idx[a], idx[b])
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]*models.Listing)
+	for i, l := range listings {
+		root := uf.find(i)
+		members[root] = append(members[root], l)
+	}
+	clusters, clustered := 0, 0
+	for _, group := range members {
+		if len(group) < 2 {
+			continue
+		}
+		canonical := group[0]
+		for _, l := range group[1:] {
+			if preferCanonical(l, canonical) {
+				canonical = l
+			}
+		}
+		for _, l := range group {
+			l.ClusterID = canonical.ListingID
+			l.IsCanonical = l == canonical
+		}
+		clusters++
+		clustered += len(group)
+	}
+
+	if clusters > 0 {
+		d.logger.Info("Found %d near-duplicate clusters covering %d listings", clusters, clustered)
+	}
+	return clusters
+}
+
+// isDuplicate decides whether two listings in the same city are the same
+// property. Hard differences (type, size, price) rule a pair out;
+// an identical photo rules it in; otherwise the descriptions, or the
+// distinctive words of the titles, must be similar enough.
+func (d *DuplicateDetector) isDuplicate(a, b *models.Listing) bool {
+	if a.PropertyType != "" && b.PropertyType != "" && a.PropertyType != b.PropertyType {
+		return false
+	}
+	if a.Bedrooms > 0 && b.Bedrooms > 0 && a.Bedrooms != b.Bedrooms {
+		return false
+	}
+	if a.Beds > 0 && b.Beds > 0 && a.Beds != b.Beds {
+		return false
+	}
+	if a.Price > 0 && b.Price > 0 && math.Abs(a.Price-b.Price)/math.Max(a.Price, b.Price) > d.priceTolerance {
+		return false
+	}
+
+	if a.PhotoHash != "" && a.PhotoHash == b.PhotoHash {
+		return true
+	}
+	if a.DescriptionClean != "" && b.DescriptionClean != "" {
+		return jaccard(textTokens(a.DescriptionClean), textTokens(b.DescriptionClean)) >= d.minSimilarity
+	}
+	ta, tb := distinctiveTitleTokens(a), distinctiveTitleTokens(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		// "Condo in Bangkok" says nothing about which condo
+		return false
+	}
+	return jaccard(ta, tb) >= d.minSimilarity
+}
+
+// preferCanonical reports whether l represents its cluster better than cur:
+// more reviews first, then having a description, then the older room ID
+func preferCanonical(l, cur *models.Listing) bool {
+	if l.ReviewCount != cur.ReviewCount {
+		return l.ReviewCount > cur.ReviewCount
+	}
+	if (l.Description != "") != (cur.Description != "") {
+		return l.Description != ""
+	}
+	if len(l.ListingID) != len(cur.ListingID) {
+		return len(l.ListingID) < len(cur.ListingID)
+	}
+	return l.ListingID < cur.ListingID
+}
+
+// photoHash identifies a card photo by its image path, ignoring the resize
+// parameters Airbnb adds to the query string
+func photoHash(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+	key := raw
+	if u, err := url.Parse(raw); err == nil && u.Path != "" {
+		key = strings.ToLower(u.Host + u.Path)
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// textTokens splits text into a set of lowercase words
+func textTokens(s string) map[string]bool {
+	tokens := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		tokens[w] = true
+	}
+	return tokens
+}
+
+// distinctiveTitleTokens are the title words left after removing stopwords,
+// property type words and the listing's own place names
+func distinctiveTitleTokens(l *models.Listing) map[string]bool {
+	tokens := textTokens(l.Title)
+	for w := range tokens {
+		if titleStopwords[w] {
+			delete(tokens, w)
+		}
+	}
+	for _, k := range propertyKeywords {
+		for w := range textTokens(k.keyword) {
+			delete(tokens, w)
+		}
+	}
+	for _, place := range []string{l.Neighborhood, l.City, l.Location} {
+		for w := range textTokens(place) {
+			delete(tokens, w)
+		}
+	}
+	return tokens
+}
+
+// jaccard is the share of words two sets have in common
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// unionFind is a disjoint-set forest over listing indexes
+type unionFind struct {
+	parent []int
+}
+
+func newUnionFind(n int) *unionFind {
+	uf := &unionFind{parent: make([]int, n)}
+	for i := range uf.parent {
+		uf.parent[i] = i
+	}
+	return uf
+}
+
+func (uf *unionFind) find(i int) int {
+	for uf.parent[i] != i {
+		uf.parent[i] = uf.parent[uf.parent[i]]
+		i = uf.parent[i]
+	}
+	return i
+}
+
+func (uf *unionFind) union(a, b int) {
+	ra, rb := uf.find(a), uf.find(b)
+	if ra != rb {
+		uf.parent[rb] = ra
+	}
+}
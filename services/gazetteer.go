@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultGazetteer is the bundled place list used when no GAZETTEER_FILE is set
+//
+//go:embed data/gazetteer.csv
+var defaultGazetteer []byte
+
+// Place kinds in the gazetteer
+const (
+	PlaceCity         = "city"
+	PlaceNeighborhood = "neighborhood"
+)
+
+// Place is a normalized location from the gazetteer
+type Place struct {
+	Kind         string // PlaceCity or PlaceNeighborhood
+	Neighborhood string // empty for cities
+	City         string
+	Region       string
+	Country      string
+	CountryCode  string  // ISO 3166-1 alpha-2
+	Latitude     float64 // 0,0 when the gazetteer has no coordinates
+	Longitude    float64
+}
+
+// gazetteerColumns is the expected CSV header
+var gazetteerColumns = []string{"kind", "name", "aliases", "city", "region", "country", "country_code", "lat", "lon"}
+
+// placeAffixes are administrative words that Airbnb sometimes adds around a
+// place name ("Khet Bang Na", "Shibuya City", "Gangnam-gu"); they are stripped
+// when the full name is not in the gazetteer
+var (
+	placePrefixes = []string{"khet ", "kecamatan ", "amphoe ", "kota ", "district of ", "city of "}
+	placeSuffixes = []string{" city", " district", " ward", " gu", " ku", " province"}
+)
+
+// Gazetteer resolves free-text location strings to normalized places offline
+type Gazetteer struct {
+	places map[string]*Place // keyed by normalized name and alias
+}
+
+// LoadGazetteer reads a gazetteer CSV, or the bundled one when path is empty
+func LoadGazetteer(path string) (*Gazetteer, error) {
+	var r io.Reader = bytes.NewReader(defaultGazetteer)
+	name := "bundled gazetteer"
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gazetteer: %w", err)
+		}
+		defer f.Close()
+		r, name = f, path
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(gazetteerColumns)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s header: %w", name, err)
+	}
+	for i, col := range gazetteerColumns {
+		if strings.TrimSpace(header[i]) != col {
+			return nil, fmt.Errorf("%s: column %d must be %q, got %q", name, i+1, col, header[i])
+		}
+	}
+
+	g := &Gazetteer{places: make(map[string]*Place)}
+	for {
+		rec, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		place, err := parsePlace(rec)
+		if err != nil {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("%s line %d: %w", name, line, err)
+		}
+		g.add(rec[1], place)
+		for _, alias := range strings.Split(rec[2], ";") {
+			g.add(alias, place)
+		}
+	}
+	if len(g.places) == 0 {
+		return nil, fmt.Errorf("%s has no places", name)
+	}
+	return g, nil
+}
+
+// parsePlace builds a Place from one gazetteer record
+func parsePlace(rec []string) (*Place, error) {
+	for i := range rec {
+		rec[i] = strings.TrimSpace(rec[i])
+	}
+	p := &Place{
+		Kind:        rec[0],
+		City:        rec[3],
+		Region:      rec[4],
+		Country:     rec[5],
+		CountryCode: strings.ToUpper(rec[6]),
+	}
+	switch p.Kind {
+	case PlaceCity:
+		if p.City == "" {
+			p.City = rec[1]
+		}
+	case PlaceNeighborhood:
+		p.Neighborhood = rec[1]
+	default:
+		return nil, fmt.Errorf("unknown kind %q", p.Kind)
+	}
+	if rec[1] == "" || p.City == "" {
+		return nil, fmt.Errorf("name and city are required")
+	}
+	if rec[7] != "" || rec[8] != "" {
+		lat, errLat := strconv.ParseFloat(rec[7], 64)
+		lon, errLon := strconv.ParseFloat(rec[8], 64)
+		if errLat != nil || errLon != nil {
+			return nil, fmt.Errorf("invalid coordinates %q,%q", rec[7], rec[8])
+		}
+		p.Latitude, p.Longitude = lat, lon
+	}
+	return p, nil
+}
+
+// add registers a name for a place. The first entry for a name wins, so
+// listing a neighborhood before a same-named city elsewhere is a choice.
+func (g *Gazetteer) add(name string, p *Place) {
+	key := normalizePlaceName(name)
+	if key == "" {
+		return
+	}
+	if _, ok := g.places[key]; !ok {
+		g.places[key] = p
+	}
+}
+
+// Lookup resolves a location string such as "Khet Bang Na", "Shinjuku City"
+// or "Ubud, Bali, Indonesia" to a place. It tries the whole string, then each
+// comma-separated part from the most specific, then the same with
+// administrative prefixes and suffixes removed.
+func (g *Gazetteer) Lookup(raw string) (*Place, bool) {
+	if g == nil {
+		return nil, false
+	}
+	candidates := []string{raw}
+	if strings.Contains(raw, ",") {
+		candidates = append(candidates, strings.Split(raw, ",")...)
+	}
+	for _, c := range candidates {
+		if p, ok := g.places[normalizePlaceName(c)]; ok {
+			return p, true
+		}
+	}
+	for _, c := range candidates {
+		if p, ok := g.places[stripPlaceAffixes(normalizePlaceName(c))]; ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// placeFolding maps accented letters to ASCII so "Tōkyō" matches "Tokyo"
+var placeFolding = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "ā", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "ē", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i", "ī", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ō", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ū", "u",
+	"ç", "c", "ñ", "n",
+)
+
+// normalizePlaceName lowercases, folds accents and reduces punctuation to
+// single spaces so "Saint-Germain-des-Prés" and "saint germain des pres" match
+func normalizePlaceName(s string) string {
+	s = placeFolding.Replace(strings.ToLower(strings.TrimSpace(s)))
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 0x7f {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(r)
+			space = false
+			continue
+		}
+		if r != '\'' && r != '’' {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+// stripPlaceAffixes removes one administrative prefix and suffix from a
+// normalized name
+func stripPlaceAffixes(s string) string {
+	for _, p := range placePrefixes {
+		if strings.HasPrefix(s, p) {
+			s = strings.TrimPrefix(s, p)
+			break
+		}
+	}
+	for _, suf := range placeSuffixes {
+		if strings.HasSuffix(s, suf) {
+			s = strings.TrimSuffix(s, suf)
+			break
+		}
+	}
+	return s
+}
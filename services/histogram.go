@@ -0,0 +1,131 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"airbnb-scraper/models"
+)
+
+// Histogram bucket limits: fewer hides the shape, more makes bars of one or two
+const (
+	minHistogramBuckets = 3
+	maxHistogramBuckets = 20
+)
+
+// minHistogramSamples is the fewest values worth drawing a distribution for
+const minHistogramSamples = 5
+
+// bucketEpsilon keeps values on a bucket edge, such as 4.8 with 0.05 wide
+// buckets, from falling into the bucket below through rounding
+const bucketEpsilon = 1e-9
+
+// newHistogram buckets values with the Freedman–Diaconis rule, width =
+// 2·IQR·n^(-1/3), which adapts to the spread of the bulk of the data and is
+// not stretched by a few extreme values. The width is rounded to a 1, 2 or 5
+// step so bucket edges read well. Returns nil for too few values.
+func newHistogram(key string, values []float64) *models.Histogram {
+	if len(values) < minHistogramSamples {
+		return nil
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+
+	h := &models.Histogram{Key: key, Total: len(sorted)}
+	if hi == lo {
+		h.Start, h.Width, h.Counts = lo, 1, []int{len(sorted)}
+		return h
+	}
+
+	iqr := percentile(sorted, 75) - percentile(sorted, 25)
+	width := 2 * iqr * math.Pow(float64(len(sorted)), -1.0/3)
+	if width <= 0 {
+		// Sturges' rule when the middle half has no spread
+		width = (hi - lo) / (math.Ceil(math.Log2(float64(len(sorted)))) + 1)
+	}
+	span := hi - lo
+	width = niceRound(width)
+	width = math.Max(width, niceCeil(span/maxHistogramBuckets))
+	width = math.Min(width, niceRound(span/minHistogramBuckets))
+
+	h.Start = math.Floor(lo/width+bucketEpsilon) * width
+	h.Width = width
+	h.Counts = make([]int, int(math.Floor((hi-h.Start)/width+bucketEpsilon))+1)
+	for _, v := range sorted {
+		i := int(math.Floor((v-h.Start)/width + bucketEpsilon))
+		if i >= len(h.Counts) { // floating point at the top edge
+			i = len(h.Counts) - 1
+		}
+		h.Counts[i]++
+	}
+	return h
+}
+
+// spearman returns the rank correlation of two equally long series, or 0
+// when either has no variation
+func spearman(xs, ys []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	rx, ry := ranks(xs), ranks(ys)
+	mx, my := mean(rx), mean(ry)
+	var cov, vx, vy float64
+	for i := range rx {
+		dx, dy := rx[i]-mx, ry[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
+// ranks returns the 1-based rank of each value, averaging ties
+func ranks(values []float64) []float64 {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return values[idx[a]] < values[idx[b]] })
+
+	r := make([]float64, len(values))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && values[idx[j+1]] == values[idx[i]] {
+			j++
+		}
+		avg := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			r[idx[k]] = avg
+		}
+		i = j + 1
+	}
+	return r
+}
+
+// niceCeil rounds v up to 1, 2 or 5 times a power of ten, for axis ends and
+// bucket widths
+func niceCeil(v float64) float64 {
+	mag := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if m*mag >= v {
+			return m * mag
+		}
+	}
+	return 10 * mag
+}
+
+// niceRound rounds v to the nearest 1, 2 or 5 times a power of ten
+func niceRound(v float64) float64 {
+	mag := math.Pow(10, math.Floor(math.Log10(v)))
+	best := mag
+	for _, m := range []float64{2, 5, 10} {
+		if math.Abs(m*mag-v) < math.Abs(best-v) {
+			best = m * mag
+		}
+	}
+	return best
+}
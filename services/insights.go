@@ -3,24 +3,35 @@ package services
 import (
 	"sort"
 
+	"airbnb-scraper/config"
 	"airbnb-scraper/models"
 	"airbnb-scraper/utils"
 )
 
 // InsightService computes analytics from the cleaned dataset
 type InsightService struct {
+	cfg    *config.Config
 	logger *utils.Logger
 }
 
 // NewInsightService creates a new InsightService
-func NewInsightService(logger *utils.Logger) *InsightService {
-	return &InsightService{logger: logger}
+func NewInsightService(cfg *config.Config, logger *utils.Logger) *InsightService {
+	if cfg.OutlierMethod != OutlierMethodIQR && cfg.OutlierMethod != OutlierMethodMAD {
+		logger.Warn("Unknown OUTLIER_METHOD %q, using %s", cfg.OutlierMethod, OutlierMethodIQR)
+	}
+	return &InsightService{cfg: cfg, logger: logger}
 }
 
 // Generate computes all required insights from a slice of clean listings
 func (s *InsightService) Generate(listings []*models.Listing) *models.InsightReport {
 	report := &models.InsightReport{
+		Currency:           s.cfg.ReportingCurrency,
 		ListingsByLocation: make(map[string]int),
+		ListingsByType:     make(map[models.PropertyType]int),
+	}
+
+	if s.cfg.CollapseDuplicates {
+		listings, report.CollapsedDuplicates = collapseDuplicates(listings)
 	}
 
 	if len(listings) == 0 {
@@ -29,8 +40,8 @@ func (s *InsightService) Generate(listings []*models.Listing) *models.InsightRep
 	}
 
 	var totalPrice float64
-	report.MinPrice = listings[0].Price
-	report.MaxPrice = listings[0].Price
+	priced := 0
+	report.OutliersExcluded = s.cfg.ExcludeOutliers
 
 	for _, l := range listings {
 		// Counts
@@ -39,9 +50,14 @@ func (s *InsightService) Generate(listings []*models.Listing) *models.InsightRep
 			report.AirbnbListings++
 		}
 
+		if l.IsOutlier {
+			report.Outliers = append(report.Outliers, l)
+		}
+
 		// Price stats
-		if l.Price > 0 {
+		if s.hasUsablePrice(l) {
 			totalPrice += l.Price
+			priced++
 			if l.Price < report.MinPrice || report.MinPrice == 0 {
 				report.MinPrice = l.Price
 			}
@@ -51,15 +67,20 @@ func (s *InsightService) Generate(listings []*models.Listing) *models.InsightRep
 			}
 		}
 
-		// Location count
-		if l.Location != "" {
-			report.ListingsByLocation[l.Location]++
+		// Location count, by normalized city so "Shinjuku" and "Tokyo" agree
+		if loc := locationKey(l); loc != "" {
+			report.ListingsByLocation[loc]++
+		}
+
+		// Property type count
+		if l.PropertyType != "" {
+			report.ListingsByType[l.PropertyType]++
 		}
 	}
 
-	// Average price
-	if report.TotalListings > 0 {
-		report.AveragePrice = totalPrice / float64(report.TotalListings)
+	// Average price over listings with a known price
+	if priced > 0 {
+		report.AveragePrice = totalPrice / float64(priced)
 	}
 
 	// If MostExpensive not set (all prices 0), just pick first
@@ -67,21 +88,149 @@ func (s *InsightService) Generate(listings []*models.Listing) *models.InsightRep
 		report.MostExpensive = listings[0]
 	}
 
-	// Top 5 highest-rated
-	rated := make([]*models.Listing, 0, len(listings))
+	// Market statistics per city and per property type
+	report.LocationStats = s.LocationStats(listings)
+	report.TypeStats = groupStats(listings, func(l *models.Listing) string {
+		if l.PropertyType == "" {
+			return ""
+		}
+		return l.PropertyType.Label()
+	}, s.hasUsablePrice)
+
+	sort.SliceStable(report.Outliers, func(i, j int) bool {
+		return report.Outliers[i].Price > report.Outliers[j].Price
+	})
+
+	// Top 5 highest-rated, by review-weighted rating
+	report.TopRated = s.topRated(listings, 5)
+
+	// Top 3 best value per city, by the scores set before storing
+	report.BestValue = s.bestValue(report.LocationStats, listings, 3)
+
+	s.distributions(report, listings)
+
+	return report
+}
+
+// topRated ranks rated listings by a Bayesian average: each rating is pulled
+// toward the dataset mean by RatingPriorReviews phantom reviews, so a 5.0 with
+// 3 reviews does not outrank a 4.95 with 900
+func (s *InsightService) topRated(listings []*models.Listing, n int) []*models.RankedListing {
+	mean, ok := meanRating(listings)
+	if !ok {
+		return nil
+	}
+	prior := float64(s.cfg.RatingPriorReviews)
+
+	var ranked []*models.RankedListing
 	for _, l := range listings {
 		if l.Rating > 0 {
-			rated = append(rated, l)
+			ranked = append(ranked, &models.RankedListing{Listing: l, Score: weightedRating(l, mean, prior)})
 		}
 	}
-	sort.Slice(rated, func(i, j int) bool {
-		return rated[i].Rating > rated[j].Rating
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
 	})
-	maxTop := 5
-	if len(rated) < maxTop {
-		maxTop = len(rated)
+
+	if len(ranked) > n {
+		ranked = ranked[:n]
 	}
-	report.TopRated = rated[:maxTop]
+	return ranked
+}
 
-	return report
-}
\ No newline at end of file
+// meanRating is the average rating of rated listings, and false when none
+// are rated
+func meanRating(listings []*models.Listing) (float64, bool) {
+	var sum float64
+	rated := 0
+	for _, l := range listings {
+		if l.Rating > 0 {
+			sum += l.Rating
+			rated++
+		}
+	}
+	if rated == 0 {
+		return 0, false
+	}
+	return sum / float64(rated), true
+}
+
+// weightedRating pulls a listing's rating toward mean by prior phantom
+// reviews. An unrated listing gets mean itself.
+func weightedRating(l *models.Listing, mean, prior float64) float64 {
+	if l.Rating <= 0 {
+		return mean
+	}
+	// A rating without a visible review count still rests on at least one review
+	reviews := float64(l.ReviewCount)
+	if reviews < 1 {
+		reviews = 1
+	}
+	return (reviews*l.Rating + prior*mean) / (reviews + prior)
+}
+
+// distributions fills in the price histograms per city, in the order of the
+// market table, the rating histogram and the price-vs-rating scatter
+func (s *InsightService) distributions(report *models.InsightReport, listings []*models.Listing) {
+	prices := make(map[string][]float64)
+	var ratings, xs, ys []float64
+	for _, l := range listings {
+		usable := s.hasUsablePrice(l)
+		if usable {
+			prices[locationKey(l)] = append(prices[locationKey(l)], l.Price)
+		}
+		if l.Rating > 0 {
+			ratings = append(ratings, l.Rating)
+			if usable {
+				xs, ys = append(xs, l.Price), append(ys, l.Rating)
+			}
+		}
+	}
+
+	for _, g := range report.LocationStats {
+		if h := newHistogram(g.Key, prices[g.Key]); h != nil {
+			report.PriceHistograms = append(report.PriceHistograms, h)
+		}
+	}
+	report.RatingHistogram = newHistogram("", ratings)
+	if len(xs) >= minHistogramSamples {
+		scatter := &models.PriceRatingScatter{Correlation: spearman(xs, ys)}
+		for i := range xs {
+			scatter.Points = append(scatter.Points, models.ScatterPoint{Price: xs[i], Rating: ys[i]})
+		}
+		report.PriceRating = scatter
+	}
+}
+
+// LocationStats summarizes listings per normalized city, largest first, as
+// in the report's market table
+func (s *InsightService) LocationStats(listings []*models.Listing) []*models.GroupStats {
+	return groupStats(listings, locationKey, s.hasUsablePrice)
+}
+
+// hasUsablePrice reports whether a listing's price counts toward price
+// statistics: it must be known and, by default, not an outlier
+func (s *InsightService) hasUsablePrice(l *models.Listing) bool {
+	return l.Price > 0 && !(s.cfg.ExcludeOutliers && l.IsOutlier)
+}
+
+// locationKey is the city a listing is grouped under, falling back to the raw
+// location for places the gazetteer does not know
+func locationKey(l *models.Listing) string {
+	if l.City != "" {
+		return l.City
+	}
+	return l.Location
+}
+
+// collapseDuplicates keeps only the canonical member of each near-duplicate
+// cluster and returns how many listings were dropped
+func collapseDuplicates(listings []*models.Listing) ([]*models.Listing, int) {
+	kept := make([]*models.Listing, 0, len(listings))
+	for _, l := range listings {
+		if l.ClusterID == "" || l.IsCanonical {
+			kept = append(kept, l)
+		}
+	}
+	return kept, len(listings) - len(kept)
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"airbnb-scraper/models"
+)
+
+// Notifier types
+const (
+	NotifierFile    = "file"
+	NotifierWebhook = "webhook"
+	NotifierSMTP    = "smtp"
+)
+
+// webhookTimeout bounds how long a slow receiver can hold up the end of a run
+const webhookTimeout = 15 * time.Second
+
+// Notifier delivers a batch of alerts somewhere
+type Notifier interface {
+	Notify(alerts []*models.Alert) error
+}
+
+// NotifierConfig is one notifier from the alert rules file. Which fields are
+// used depends on Type.
+type NotifierConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// file
+	Path string `json:"path,omitempty"`
+
+	// webhook
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// smtp; the password is read from the environment variable PasswordEnv
+	// so it does not have to live in the rules file
+	Host        string   `json:"host,omitempty"`
+	Port        int      `json:"port,omitempty"`
+	Username    string   `json:"username,omitempty"`
+	PasswordEnv string   `json:"password_env,omitempty"`
+	From        string   `json:"from,omitempty"`
+	To          []string `json:"to,omitempty"`
+}
+
+// NewNotifier builds the notifier described by cfg
+func NewNotifier(cfg *NotifierConfig) (Notifier, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	switch cfg.Type {
+	case NotifierFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("file notifier needs path")
+		}
+		return &FileNotifier{path: cfg.Path}, nil
+	case NotifierWebhook:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook notifier needs url")
+		}
+		return &WebhookNotifier{url: cfg.URL, headers: cfg.Headers, client: &http.Client{Timeout: webhookTimeout}}, nil
+	case NotifierSMTP:
+		if cfg.Host == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("smtp notifier needs host, from and to")
+		}
+		port := cfg.Port
+		if port == 0 {
+			port = 587
+		}
+		n := &SMTPNotifier{addr: net.JoinHostPort(cfg.Host, strconv.Itoa(port)), from: cfg.From, to: cfg.To}
+		if cfg.Username != "" {
+			n.auth = smtp.PlainAuth("", cfg.Username, os.Getenv(cfg.PasswordEnv), cfg.Host)
+		}
+		return n, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q (available: file, webhook, smtp)", cfg.Type)
+	}
+}
+
+// FileNotifier appends alerts to a JSON Lines file
+type FileNotifier struct {
+	path string
+}
+
+func (n *FileNotifier) Notify(alerts []*models.Alert) error {
+	if err := os.MkdirAll(filepath.Dir(n.path), 0755); err != nil {
+		return fmt.Errorf("failed to create alert directory: %w", err)
+	}
+	file, err := os.OpenFile(n.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open alert file: %w", err)
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	for _, a := range alerts {
+		if err := enc.Encode(a); err != nil {
+			return fmt.Errorf("failed to write alert: %w", err)
+		}
+	}
+	return nil
+}
+
+// WebhookNotifier POSTs {"alerts": [...]} as JSON to a URL
+type WebhookNotifier struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (n *WebhookNotifier) Notify(alerts []*models.Alert) error {
+	body, err := json.Marshal(map[string]interface{}{"alerts": alerts})
+	if err != nil {
+		return fmt.Errorf("failed to encode alerts: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier emails a plain-text digest of the alerts
+type SMTPNotifier struct {
+	addr string
+	auth smtp.Auth // nil when the server needs no login
+	from string
+	to   []string
+}
+
+func (n *SMTPNotifier) Notify(alerts []*models.Alert) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&b, "Subject: %d Airbnb listing alerts\r\n", len(alerts))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	for _, a := range alerts {
+		fmt.Fprintf(&b, "[%s] %s (%s)\r\n", a.Rule, a.Title, a.Location)
+		fmt.Fprintf(&b, "  %s/night: %s\r\n", formatMoney(a.Price, a.Currency), strings.Join(a.Reasons, ", "))
+		fmt.Fprintf(&b, "  %s\r\n\r\n", a.URL)
+	}
+
+	if err := smtp.SendMail(n.addr, n.auth, n.from, n.to, []byte(b.String())); err != nil {
+		return fmt.Errorf("failed to send alert email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"airbnb-scraper/models"
+)
+
+// Outlier detection methods
+const (
+	OutlierMethodIQR = "iqr"
+	OutlierMethodMAD = "mad"
+)
+
+// madScale makes the median absolute deviation comparable to a standard
+// deviation for normally distributed prices
+const madScale = 0.6745
+
+// FlagOutliers sets IsOutlier on listings whose nightly price is far outside
+// the range of their location, and returns how many were flagged. Robust
+// statistics are used so the outliers themselves cannot widen the range the
+// way they would a mean and standard deviation. Call it before storing the
+// listings so the flag is persisted.
+func (s *InsightService) FlagOutliers(listings []*models.Listing) int {
+	byLocation := make(map[string][]*models.Listing)
+	for _, l := range listings {
+		l.IsOutlier = false
+		if l.Price > 0 {
+			byLocation[locationKey(l)] = append(byLocation[locationKey(l)], l)
+		}
+	}
+
+	flagged := 0
+	for loc, group := range byLocation {
+		if len(group) < s.cfg.OutlierMinSamples {
+			continue
+		}
+		prices := make([]float64, len(group))
+		for i, l := range group {
+			prices[i] = l.Price
+		}
+		sort.Float64s(prices)
+
+		isOutlier, ok := s.outlierTest(prices)
+		if !ok {
+			s.logger.Debug("Skipping outlier check for %s: prices have no spread", loc)
+			continue
+		}
+		for _, l := range group {
+			if isOutlier(l.Price) {
+				l.IsOutlier = true
+				flagged++
+			}
+		}
+	}
+
+	if flagged > 0 {
+		s.logger.Info("Flagged %d price outliers (%s)", flagged, s.cfg.OutlierMethod)
+	}
+	return flagged
+}
+
+// outlierTest builds the configured test for one location's sorted prices.
+// It reports false when the spread is zero, since every deviation would
+// then count as infinitely far out.
+func (s *InsightService) outlierTest(sorted []float64) (func(float64) bool, bool) {
+	if s.cfg.OutlierMethod == OutlierMethodMAD {
+		median := percentile(sorted, 50)
+		deviations := make([]float64, len(sorted))
+		for i, p := range sorted {
+			deviations[i] = math.Abs(p - median)
+		}
+		sort.Float64s(deviations)
+		mad := percentile(deviations, 50)
+		if mad == 0 {
+			return nil, false
+		}
+		return func(p float64) bool {
+			return madScale*math.Abs(p-median)/mad > s.cfg.OutlierMADThreshold
+		}, true
+	}
+
+	q1, q3 := percentile(sorted, 25), percentile(sorted, 75)
+	iqr := q3 - q1
+	if iqr == 0 {
+		return nil, false
+	}
+	low := q1 - s.cfg.OutlierIQRMultiplier*iqr
+	high := q3 + s.cfg.OutlierIQRMultiplier*iqr
+	return func(p float64) bool { return p < low || p > high }, true
+}
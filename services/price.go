@@ -0,0 +1,178 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	forNightsRegex     = regexp.MustCompile(`(?i)\bfor\s*(\d{1,3})\s*nights?\b`)
+	timesNightsRegex   = regexp.MustCompile(`(?i)(?:\bx|×)\s*(\d{1,3})\s*nights?\b`)
+	nightsCountRegex   = regexp.MustCompile(`(?i)(?:^|[\s·,(])(\d{1,3})\s*nights\b`)
+	originalRegex      = regexp.MustCompile(`(?i)(originally|was|previous price|before discount)\s*:?\s*$`)
+	taxesIncludedRegex = regexp.MustCompile(`(?i)(includ\w*\s+(all\s+)?(taxes|fees)|incl\.?\s+(taxes|fees)|taxes\s+(and\s+fees\s+)?included)`)
+)
+
+// Price units as displayed on a card
+const (
+	unitNight = "night"
+	unitTotal = "total"
+)
+
+// PriceBreakdown is the structured reading of an Airbnb price string such as
+// "$120 $95 night", "$540 total before taxes", "$71 for 2 nights" or
+// "$95 x 5 nights".
+// All amounts are in Currency; zero means "not shown".
+type PriceBreakdown struct {
+	Currency         string
+	Nightly          float64 // price per night actually charged
+	PreDiscountPrice float64 // crossed-out nightly price before a discount
+	DiscountPct      float64
+	Total            float64 // total stay price
+	Nights           int
+	TaxesIncluded    bool
+}
+
+// priceAmount is an amount found in a price string with the words around it
+type priceAmount struct {
+	value    float64
+	original bool   // introduced by "originally", "was", ...
+	unit     string // unitNight, unitTotal or "" when the label says neither
+}
+
+// parsePrice reads nightly, original and total prices from a raw price string.
+// Airbnb renders a discount either as "$95 per night, originally $120" or as
+// two bare amounts "$120 $95 night" where the first is the crossed-out price.
+// When only a total is shown, the nightly price is derived from the number of
+// nights; without it the nightly price stays unknown rather than guessing.
+func parsePrice(raw string) PriceBreakdown {
+	b := PriceBreakdown{Currency: detectCurrency(raw)}
+	if strings.TrimSpace(raw) == "" {
+		return b
+	}
+	b.TaxesIncluded = taxesIncludedRegex.MatchString(raw)
+
+	// "for 2 nights" both gives the stay length and marks the amount as a total,
+	// while "x 5 nights" multiplies a nightly amount
+	stayIsTotal := false
+	if m := forNightsRegex.FindStringSubmatch(raw); len(m) == 2 {
+		b.Nights, _ = strconv.Atoi(m[1])
+		stayIsTotal = true
+		raw = strings.Replace(raw, m[0], " total ", 1)
+	} else if m := timesNightsRegex.FindStringSubmatch(raw); len(m) == 2 {
+		b.Nights, _ = strconv.Atoi(m[1])
+		raw = strings.Replace(raw, m[0], " night ", 1)
+	} else if m := nightsCountRegex.FindStringSubmatch(raw); len(m) == 2 {
+		b.Nights, _ = strconv.Atoi(m[1])
+		raw = strings.Replace(raw, m[0], " ", 1)
+	}
+
+	amounts := splitPriceAmounts(raw, b.Currency)
+	if len(amounts) == 0 {
+		return b
+	}
+
+	// Two bare amounts followed by one label: the first is the crossed-out price
+	var current, original *priceAmount
+	for i := range amounts {
+		a := &amounts[i]
+		if a.original {
+			original = a
+			continue
+		}
+		if current == nil {
+			current = a
+			continue
+		}
+		if original == nil && current.unit == "" && a.value < current.value {
+			original, current = current, a
+			continue
+		}
+		if a.unit == unitTotal && current.unit != unitTotal && b.Total == 0 {
+			b.Total = a.value
+		}
+	}
+	if current == nil {
+		return b
+	}
+
+	unit := current.unit
+	if unit == "" && original != nil {
+		unit = original.unit
+	}
+	if unit == "" && stayIsTotal {
+		unit = unitTotal
+	}
+	if unit == "" {
+		unit = unitNight
+	}
+
+	switch unit {
+	case unitTotal:
+		b.Total = current.value
+		if b.Nights > 0 {
+			b.Nightly = current.value / float64(b.Nights)
+		}
+	default:
+		b.Nightly = current.value
+		if b.Total == 0 && b.Nights > 0 {
+			b.Total = current.value * float64(b.Nights)
+		}
+	}
+
+	if original != nil && original.value > current.value {
+		b.DiscountPct = (original.value - current.value) / original.value * 100
+		switch {
+		case unit == unitNight:
+			b.PreDiscountPrice = original.value
+		case b.Nights > 0:
+			b.PreDiscountPrice = original.value / float64(b.Nights)
+		}
+	}
+	return b
+}
+
+// splitPriceAmounts finds every amount in raw and classifies it by the text
+// before it ("originally") and the label after it ("night", "total")
+func splitPriceAmounts(raw, currency string) []priceAmount {
+	locs := amountRegex.FindAllStringIndex(raw, -1)
+	var amounts []priceAmount
+	for i, loc := range locs {
+		v, ok := parseAmount(raw[loc[0]:loc[1]], currency)
+		if !ok {
+			continue
+		}
+
+		prevEnd := 0
+		if i > 0 {
+			prevEnd = locs[i-1][1]
+		}
+		nextStart := len(raw)
+		if i+1 < len(locs) {
+			nextStart = locs[i+1][0]
+		}
+		before := stripCurrencyMarkers(raw[prevEnd:loc[0]])
+		label := strings.ToLower(raw[loc[1]:nextStart])
+
+		a := priceAmount{value: v, original: originalRegex.MatchString(before)}
+		switch {
+		case strings.Contains(label, "total"):
+			a.unit = unitTotal
+		case strings.Contains(label, "night"):
+			a.unit = unitNight
+		}
+		amounts = append(amounts, a)
+	}
+	return amounts
+}
+
+// stripCurrencyMarkers removes symbols and codes so "originally $" still
+// reads as ending in "originally"
+func stripCurrencyMarkers(s string) string {
+	s = alphaCurrencyRegex.ReplaceAllString(s, " ")
+	for _, t := range currencyTokens {
+		s = strings.ReplaceAll(s, t.token, "")
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// priceCase is one price string of the corpus and the breakdown it reads as
+type priceCase struct {
+	raw  string
+	want string
+}
+
+// readPriceCorpus reads testdata/prices.golden: a quoted price string per
+// line, followed by a "=> " line with its breakdown. Comments start with #.
+func readPriceCorpus(t *testing.T, path string) (header []string, cases []priceCase) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open corpus: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "#") && len(cases) == 0:
+			header = append(header, text)
+		case strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#"):
+		case strings.HasPrefix(text, "=> "):
+			if len(cases) == 0 || cases[len(cases)-1].want != "" {
+				t.Fatalf("%s:%d: breakdown without a price string", path, line)
+			}
+			cases[len(cases)-1].want = strings.TrimPrefix(text, "=> ")
+		default:
+			raw, err := strconv.Unquote(text)
+			if err != nil {
+				t.Fatalf("%s:%d: price strings must be quoted: %v", path, line, err)
+			}
+			cases = append(cases, priceCase{raw: raw})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read corpus: %v", err)
+	}
+	return header, cases
+}
+
+func formatBreakdown(b PriceBreakdown) string {
+	return fmt.Sprintf("currency=%s nightly=%s pre_discount=%s discount_pct=%s total=%s nights=%d taxes_included=%t",
+		b.Currency, numeric(b.Nightly), numeric(b.PreDiscountPrice), numeric(b.DiscountPct),
+		numeric(b.Total), b.Nights, b.TaxesIncluded)
+}
+
+func numeric(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func TestParsePrice(t *testing.T) {
+	path := filepath.Join("testdata", "prices.golden")
+	header, cases := readPriceCorpus(t, path)
+	if len(cases) == 0 {
+		t.Fatal("empty corpus")
+	}
+
+	if *update {
+		var b strings.Builder
+		for _, h := range header {
+			b.WriteString(h + "\n")
+		}
+		for _, c := range cases {
+			fmt.Fprintf(&b, "\n%s\n=> %s\n", strconv.Quote(c.raw), formatBreakdown(parsePrice(c.raw)))
+		}
+		if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+			t.Fatalf("failed to update corpus: %v", err)
+		}
+		return
+	}
+
+	for _, c := range cases {
+		t.Run(c.raw, func(t *testing.T) {
+			if got := formatBreakdown(parsePrice(c.raw)); got != c.want {
+				t.Errorf("parsePrice(%q)\n got: %s\nwant: %s", c.raw, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"airbnb-scraper/models"
+)
+
+var (
+	bedroomsRegex = regexp.MustCompile(`(?i)(\d+)\s*bedrooms?\b`)
+	bedsRegex     = regexp.MustCompile(`(?i)(\d+)\s*beds?\b`)
+)
+
+// propertyKeywords maps words in a card title to a property type. Order
+// matters: more specific phrases come before the words they contain.
+var propertyKeywords = []struct {
+	keyword string
+	kind    models.PropertyType
+}{
+	{"shared room", models.PropertySharedRoom},
+	{"hotel", models.PropertyHotelRoom},
+	{"hostel", models.PropertyHostel},
+	{"serviced apartment", models.PropertyServicedApartment},
+	{"aparthotel", models.PropertyServicedApartment},
+	{"guest suite", models.PropertyGuestSuite},
+	{"guesthouse", models.PropertyGuesthouse},
+	{"guest house", models.PropertyGuesthouse},
+	{"room", models.PropertyPrivateRoom},
+	{"condo", models.PropertyCondo},
+	{"rental unit", models.PropertyApartment},
+	{"apartment", models.PropertyApartment},
+	{"flat", models.PropertyApartment},
+	{"townhouse", models.PropertyTownhouse},
+	{"villa", models.PropertyVilla},
+	{"loft", models.PropertyLoft},
+	{"cabin", models.PropertyCabin},
+	{"bungalow", models.PropertyBungalow},
+	{"cottage", models.PropertyCottage},
+	{"house", models.PropertyHouse},
+	{"home", models.PropertyEntireHome},
+}
+
+// parsePropertyTitle splits a card title like "Condo in Bangkok" or
+// "Room in Shinjuku" into a normalized property type and the neighborhood
+func parsePropertyTitle(title string) (models.PropertyType, string) {
+	title = strings.TrimSpace(title)
+	kindText, neighborhood := title, ""
+	if idx := strings.Index(title, " in "); idx != -1 {
+		kindText, neighborhood = title[:idx], strings.TrimSpace(title[idx+4:])
+	}
+
+	lower := strings.ToLower(kindText)
+	for _, k := range propertyKeywords {
+		if strings.Contains(lower, k.keyword) {
+			return k.kind, neighborhood
+		}
+	}
+	if lower == "" {
+		return "", neighborhood
+	}
+	return models.PropertyOther, neighborhood
+}
+
+// parseCapacity reads bed and bedroom counts from card subtitles such as
+// "2 bedrooms · 3 beds" or "Studio · 1 bed". Studios and subtitles without a
+// count leave bedrooms at 0.
+func parseCapacity(subtitle string) (beds, bedrooms int) {
+	if m := bedroomsRegex.FindStringSubmatch(subtitle); len(m) == 2 {
+		bedrooms, _ = strconv.Atoi(m[1])
+	}
+	if m := bedsRegex.FindStringSubmatch(subtitle); len(m) == 2 {
+		beds, _ = strconv.Atoi(m[1])
+	}
+	return beds, bedrooms
+}
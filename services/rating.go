@@ -0,0 +1,62 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// "4.82 out of 5", "5 out of 5"
+	outOfFiveRegex = regexp.MustCompile(`(?i)(\d(?:[.,]\d{1,2})?)\s*out of 5`)
+	// a bare rating like "4.82" or "4,9" that is not part of a longer number
+	bareRatingRegex = regexp.MustCompile(`(?:^|[^\d.,])([0-5][.,]\d{1,2})(?:[^\d.,]|$)`)
+	// "312 reviews", "1,204 reviews" or "(312)"
+	reviewCountRegex = regexp.MustCompile(`(?i)(\d[\d,.]*)\s*reviews?|\((\d[\d,.]*)\)`)
+	newListingRegex  = regexp.MustCompile(`(?i)\bnew\b`)
+)
+
+// RatingInfo is the structured reading of an Airbnb rating string
+type RatingInfo struct {
+	Rating      float64 // 0 when the listing has no rating yet
+	ReviewCount int
+	IsNew       bool // shown as "New" instead of a rating
+}
+
+// parseRating extracts the rating, review count and "New" label from strings
+// like "4.82 out of 5 average rating, 312 reviews", "4.82 (312)" or "New"
+func parseRating(raw string) RatingInfo {
+	var info RatingInfo
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return info
+	}
+
+	ratingText := ""
+	if m := outOfFiveRegex.FindStringSubmatch(raw); len(m) == 2 {
+		ratingText = m[1]
+	} else if m := bareRatingRegex.FindStringSubmatch(raw); len(m) == 2 {
+		ratingText = m[1]
+	}
+	if ratingText != "" {
+		val, err := strconv.ParseFloat(strings.Replace(ratingText, ",", ".", 1), 64)
+		// Sanity: rating should be between 0 and 5
+		if err == nil && val >= 0 && val <= 5 {
+			info.Rating = val
+		}
+	}
+
+	if m := reviewCountRegex.FindStringSubmatch(raw); m != nil {
+		count := m[1]
+		if count == "" {
+			count = m[2]
+		}
+		n, err := strconv.Atoi(strings.NewReplacer(",", "", ".", "").Replace(count))
+		if err == nil {
+			info.ReviewCount = n
+		}
+	}
+
+	info.IsNew = info.Rating == 0 && newListingRegex.MatchString(raw)
+	return info
+}
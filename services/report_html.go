@@ -0,0 +1,265 @@
+package services
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+
+	"airbnb-scraper/models"
+)
+
+// HTMLReporter renders the report as a single self-contained HTML page. Charts
+// are inline SVG and styles are inline CSS, so the file can be archived or
+// mailed without any external assets.
+type HTMLReporter struct{}
+
+// Chart geometry, in SVG user units
+const (
+	chartWidth    = 640
+	chartLabelW   = 140 // left column for row labels
+	chartRowH     = 22
+	chartPadding  = 8
+	chartAxisH    = 24 // bottom axis labels
+	chartBarColor = "#4c78a8"
+)
+
+const htmlStyle = `body{font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;max-width:960px;margin:2em auto;padding:0 1em;color:#222}
+h1{border-bottom:2px solid #222;padding-bottom:.3em}h2{margin-top:2em;border-bottom:1px solid #ccc;padding-bottom:.2em}
+table{border-collapse:collapse;margin:1em 0;font-size:14px}th,td{padding:4px 10px;border-bottom:1px solid #eee;text-align:left}
+td.n,th.n{text-align:right;font-variant-numeric:tabular-nums}svg{display:block;margin:1em 0}svg text{font-size:12px;fill:#333}
+.muted{color:#777}`
+
+func (HTMLReporter) Render(w io.Writer, report *models.InsightReport) error {
+	var b strings.Builder
+	cur := report.Currency
+	if cur == "" {
+		cur = "USD"
+	}
+
+	b.WriteString("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<title>Vacation Rental Market Insights</title>\n<style>" + htmlStyle + "</style>\n</head>\n<body>\n")
+	b.WriteString("<h1>Vacation Rental Market Insights</h1>\n")
+
+	b.WriteString("<h2>Overview</h2>\n<table>\n")
+	htmlRow(&b, "Total listings scraped", fmt.Sprint(report.TotalListings))
+	htmlRow(&b, "Airbnb listings", fmt.Sprint(report.AirbnbListings))
+	if report.CollapsedDuplicates > 0 {
+		htmlRow(&b, "Near-duplicates hidden", fmt.Sprint(report.CollapsedDuplicates))
+	}
+	htmlRow(&b, "Average price/night", formatMoney(report.AveragePrice, cur))
+	htmlRow(&b, "Minimum price/night", formatMoney(report.MinPrice, cur))
+	htmlRow(&b, "Maximum price/night", formatMoney(report.MaxPrice, cur))
+	b.WriteString("</table>\n")
+
+	if l := report.MostExpensive; l != nil {
+		b.WriteString("<h2>Most Expensive Property</h2>\n")
+		fmt.Fprintf(&b, "<p>%s in %s, %s/night</p>\n", htmlLink(l.Title, l.URL), esc(locationKey(l)), formatMoney(l.Price, cur))
+	}
+
+	if len(report.ListingsByLocation) > 0 {
+		b.WriteString("<h2>Listings per Location</h2>\n")
+		b.WriteString(svgBarChart(sortedCounts(report.ListingsByLocation)))
+	}
+	if len(report.ListingsByType) > 0 {
+		byType := make(map[string]int, len(report.ListingsByType))
+		for t, cnt := range report.ListingsByType {
+			byType[t.Label()] += cnt
+		}
+		b.WriteString("<h2>Listings per Property Type</h2>\n")
+		b.WriteString(svgBarChart(sortedCounts(byType)))
+	}
+
+	if len(report.LocationStats) > 0 {
+		fmt.Fprintf(&b, "<h2>Nightly Price Distribution by Location (%s)</h2>\n", esc(cur))
+		b.WriteString("<p class=\"muted\">Whiskers span P10–P90, boxes P25–P75; the dark line is the median.</p>\n")
+		b.WriteString(svgPriceBoxes(report.LocationStats, cur))
+	}
+	htmlGroupStats(&b, "Market by Location", "Location", report.LocationStats, cur)
+	htmlGroupStats(&b, "Market by Property Type", "Type", report.TypeStats, cur)
+
+	if len(report.Outliers) > 0 {
+		note := "included in"
+		if report.OutliersExcluded {
+			note = "excluded from"
+		}
+		b.WriteString("<h2>Price Outliers</h2>\n")
+		fmt.Fprintf(&b, "<p class=\"muted\">%d listings, %s the price figures above.</p>\n", len(report.Outliers), note)
+		b.WriteString("<table>\n<tr><th>Listing</th><th>Location</th><th class=\"n\">Price/night</th></tr>\n")
+		for _, l := range report.Outliers {
+			fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td class=\"n\">%s</td></tr>\n",
+				htmlLink(l.Title, l.URL), esc(locationKey(l)), formatMoney(l.Price, cur))
+		}
+		b.WriteString("</table>\n")
+	}
+
+	if len(report.TopRated) > 0 {
+		fmt.Fprintf(&b, "<h2>Top %d Highest Rated Properties</h2>\n", len(report.TopRated))
+		b.WriteString("<table>\n<tr><th class=\"n\">#</th><th>Listing</th><th class=\"n\">Rating</th><th class=\"n\">Reviews</th><th class=\"n\">Score</th></tr>\n")
+		for i, r := range report.TopRated {
+			fmt.Fprintf(&b, "<tr><td class=\"n\">%d</td><td>%s</td><td class=\"n\">%.2f</td><td class=\"n\">%d</td><td class=\"n\">%.2f</td></tr>\n",
+				i+1, htmlLink(r.Listing.Title, r.Listing.URL), r.Listing.Rating, r.Listing.ReviewCount, r.Score)
+		}
+		b.WriteString("</table>\n")
+	}
+
+	if len(report.BestValue) > 0 {
+		b.WriteString("<h2>Best Value by Location</h2>\n")
+		b.WriteString("<p class=\"muted\">Value score 0-100: price against the local median, rating, reviews and beds.</p>\n")
+		b.WriteString("<table>\n<tr><th>Location</th><th class=\"n\">#</th><th>Listing</th><th class=\"n\">Price/night</th><th class=\"n\">Rating</th><th class=\"n\">Score</th></tr>\n")
+		for _, lr := range report.BestValue {
+			for i, r := range lr.Listings {
+				fmt.Fprintf(&b, "<tr><td>%s</td><td class=\"n\">%d</td><td>%s</td><td class=\"n\">%s</td><td class=\"n\">%.2f</td><td class=\"n\">%.1f</td></tr>\n",
+					esc(lr.Location), i+1, htmlLink(r.Listing.Title, r.Listing.URL), esc(formatMoney(r.Listing.Price, cur)), r.Listing.Rating, r.Score)
+			}
+		}
+		b.WriteString("</table>\n")
+	}
+
+	if t := report.Trends; t != nil {
+		b.WriteString("<h2>Trends</h2>\n")
+		fmt.Fprintf(&b, "<p class=\"muted\">%d runs in the last %d days, compared with run %s (%s).</p>\n",
+			t.Runs, t.WindowDays, esc(t.BaselineRunID), t.BaselineAt.Format("2006-01-02"))
+		b.WriteString("<table>\n<tr><th>Location</th><th class=\"n\">Median</th><th class=\"n\">Change</th><th class=\"n\">Rating</th><th class=\"n\">Drift</th><th class=\"n\">New</th><th class=\"n\">Gone</th></tr>\n")
+		for _, lt := range t.Locations {
+			change, drift := "-", "-"
+			if lt.BaselineMedian > 0 && lt.MedianPrice > 0 {
+				change = fmt.Sprintf("%+.1f%%", lt.MedianChangePct)
+			}
+			if lt.BaselineRating > 0 && lt.AverageRating > 0 {
+				drift = fmt.Sprintf("%+.2f", lt.RatingDrift)
+			}
+			fmt.Fprintf(&b, "<tr><td>%s</td><td class=\"n\">%.2f</td><td class=\"n\">%s</td><td class=\"n\">%.2f</td><td class=\"n\">%s</td><td class=\"n\">%d</td><td class=\"n\">%d</td></tr>\n",
+				esc(lt.Location), lt.MedianPrice, change, lt.AverageRating, drift, lt.New, lt.Disappeared)
+		}
+		b.WriteString("</table>\n")
+		if len(t.PriceChanges) > 0 {
+			b.WriteString("<h3>Price Changes</h3>\n<table>\n<tr><th>Listing</th><th>Location</th><th class=\"n\">Old</th><th class=\"n\">New</th><th class=\"n\">Change</th></tr>\n")
+			for _, c := range t.PriceChanges {
+				fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td class=\"n\">%s</td><td class=\"n\">%s</td><td class=\"n\">%+.1f%%</td></tr>\n",
+					esc(c.Title), esc(c.Location), formatMoney(c.OldPrice, cur), formatMoney(c.NewPrice, cur), c.ChangePct)
+			}
+			b.WriteString("</table>\n")
+		}
+	}
+
+	b.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func htmlGroupStats(b *strings.Builder, title, keyLabel string, stats []*models.GroupStats, currency string) {
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "<h2>%s (nightly, %s)</h2>\n<table>\n", esc(title), esc(currency))
+	fmt.Fprintf(b, "<tr><th>%s</th><th class=\"n\">N</th><th class=\"n\">P10</th><th class=\"n\">P25</th><th class=\"n\">Median</th>"+
+		"<th class=\"n\">P75</th><th class=\"n\">P90</th><th class=\"n\">Mean</th><th class=\"n\">Rating</th><th class=\"n\">Superhosts</th></tr>\n", esc(keyLabel))
+	for _, g := range stats {
+		rating := "-"
+		if g.RatedCount > 0 {
+			rating = fmt.Sprintf("%.2f", g.AverageRating)
+		}
+		prices := []string{"-", "-", "-", "-", "-", "-"}
+		if g.PricedCount > 0 {
+			for i, p := range []float64{g.P10Price, g.P25Price, g.MedianPrice, g.P75Price, g.P90Price, g.MeanPrice} {
+				prices[i] = fmt.Sprintf("%.2f", p)
+			}
+		}
+		fmt.Fprintf(b, "<tr><td>%s</td><td class=\"n\">%d</td>", esc(g.Key), g.Count)
+		for _, p := range prices {
+			fmt.Fprintf(b, "<td class=\"n\">%s</td>", p)
+		}
+		fmt.Fprintf(b, "<td class=\"n\">%s</td><td class=\"n\">%.0f%%</td></tr>\n", rating, g.SuperhostShare*100)
+	}
+	b.WriteString("</table>\n")
+}
+
+// svgBarChart draws one horizontal bar per row, longest first
+func svgBarChart(rows []keyCount) string {
+	if len(rows) == 0 {
+		return ""
+	}
+	maxCount := rows[0].count
+	for _, r := range rows {
+		if r.count > maxCount {
+			maxCount = r.count
+		}
+	}
+	plotW := float64(chartWidth - chartLabelW - 50) // room for the count after the bar
+	height := len(rows)*chartRowH + 2*chartPadding
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" role=\"img\">\n",
+		chartWidth, height, chartWidth, height)
+	for i, r := range rows {
+		y := chartPadding + i*chartRowH
+		barW := 0.0
+		if maxCount > 0 {
+			barW = plotW * float64(r.count) / float64(maxCount)
+		}
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"end\">%s</text>\n", chartLabelW-6, y+15, esc(truncate(r.key, 20)))
+		fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%.1f\" height=\"%d\" fill=\"%s\"/>\n", chartLabelW, y+3, barW, chartRowH-6, chartBarColor)
+		fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%d\">%d</text>\n", float64(chartLabelW)+barW+6, y+15, r.count)
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// svgPriceBoxes draws a box plot of nightly prices per group on a shared axis
+func svgPriceBoxes(stats []*models.GroupStats, currency string) string {
+	var priced []*models.GroupStats
+	maxPrice := 0.0
+	for _, g := range stats {
+		if g.PricedCount == 0 {
+			continue
+		}
+		priced = append(priced, g)
+		maxPrice = math.Max(maxPrice, g.P90Price)
+	}
+	if len(priced) == 0 || maxPrice <= 0 {
+		return ""
+	}
+	axisMax := niceCeil(maxPrice)
+	plotW := float64(chartWidth - chartLabelW - 2*chartPadding)
+	x := func(p float64) float64 { return float64(chartLabelW) + plotW*p/axisMax }
+	height := len(priced)*chartRowH + 2*chartPadding + chartAxisH
+	axisY := chartPadding + len(priced)*chartRowH
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" role=\"img\">\n",
+		chartWidth, height, chartWidth, height)
+	for i, g := range priced {
+		y := chartPadding + i*chartRowH
+		mid := float64(y) + chartRowH/2
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"end\">%s</text>\n", chartLabelW-6, y+15, esc(truncate(g.Key, 20)))
+		fmt.Fprintf(&b, "<line x1=\"%.1f\" y1=\"%.1f\" x2=\"%.1f\" y2=\"%.1f\" stroke=\"#888\"/>\n", x(g.P10Price), mid, x(g.P90Price), mid)
+		fmt.Fprintf(&b, "<rect x=\"%.1f\" y=\"%d\" width=\"%.1f\" height=\"%d\" fill=\"%s\" fill-opacity=\"0.35\" stroke=\"%s\"/>\n",
+			x(g.P25Price), y+4, math.Max(x(g.P75Price)-x(g.P25Price), 1), chartRowH-8, chartBarColor, chartBarColor)
+		fmt.Fprintf(&b, "<line x1=\"%.1f\" y1=\"%d\" x2=\"%.1f\" y2=\"%d\" stroke=\"#1b3a5c\" stroke-width=\"2\"><title>median %s</title></line>\n",
+			x(g.MedianPrice), y+3, x(g.MedianPrice), y+chartRowH-3, esc(formatMoney(g.MedianPrice, currency)))
+	}
+	fmt.Fprintf(&b, "<line x1=\"%d\" y1=\"%d\" x2=\"%.1f\" y2=\"%d\" stroke=\"#333\"/>\n", chartLabelW, axisY, x(axisMax), axisY)
+	for i := 0; i <= 4; i++ {
+		v := axisMax * float64(i) / 4
+		fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", x(v), axisY+16, esc(formatMoney(v, currency)))
+	}
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+func htmlRow(b *strings.Builder, label, value string) {
+	fmt.Fprintf(b, "<tr><th>%s</th><td class=\"n\">%s</td></tr>\n", esc(label), esc(value))
+}
+
+func htmlLink(text, url string) string {
+	if url == "" {
+		return esc(text)
+	}
+	return fmt.Sprintf("<a href=\"%s\">%s</a>", esc(url), esc(text))
+}
+
+func esc(s string) string {
+	return html.EscapeString(s)
+}
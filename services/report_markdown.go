@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"airbnb-scraper/models"
+)
+
+// MarkdownReporter renders the report as GitHub-flavored Markdown, for
+// publishing in a repository, wiki or pull request
+type MarkdownReporter struct{}
+
+func (MarkdownReporter) Render(w io.Writer, report *models.InsightReport) error {
+	var b strings.Builder
+	cur := report.Currency
+
+	b.WriteString("# Vacation Rental Market Insights\n\n")
+	b.WriteString("## Overview\n\n")
+	b.WriteString("| Metric | Value |\n|---|---:|\n")
+	fmt.Fprintf(&b, "| Total listings scraped | %d |\n", report.TotalListings)
+	fmt.Fprintf(&b, "| Airbnb listings | %d |\n", report.AirbnbListings)
+	if report.CollapsedDuplicates > 0 {
+		fmt.Fprintf(&b, "| Near-duplicates hidden | %d |\n", report.CollapsedDuplicates)
+	}
+	fmt.Fprintf(&b, "| Average price/night | %s |\n", formatMoney(report.AveragePrice, cur))
+	fmt.Fprintf(&b, "| Minimum price/night | %s |\n", formatMoney(report.MinPrice, cur))
+	fmt.Fprintf(&b, "| Maximum price/night | %s |\n", formatMoney(report.MaxPrice, cur))
+
+	if l := report.MostExpensive; l != nil {
+		b.WriteString("\n## Most Expensive Property\n\n")
+		fmt.Fprintf(&b, "%s in %s, %s/night\n", mdLink(l.Title, l.URL), mdEscape(locationKey(l)),
+			formatMoney(l.Price, cur))
+	}
+
+	mdCounts(&b, "Listings per Location", "Location", report.ListingsByLocation)
+	byType := make(map[string]int, len(report.ListingsByType))
+	for t, cnt := range report.ListingsByType {
+		byType[t.Label()] += cnt
+	}
+	mdCounts(&b, "Listings per Property Type", "Type", byType)
+
+	mdGroupStats(&b, "Market by Location", "Location", report.LocationStats, cur)
+	mdGroupStats(&b, "Market by Property Type", "Type", report.TypeStats, cur)
+
+	if len(report.Outliers) > 0 {
+		note := "included in"
+		if report.OutliersExcluded {
+			note = "excluded from"
+		}
+		fmt.Fprintf(&b, "\n## Price Outliers\n\n%d listings, %s the price figures above.\n\n", len(report.Outliers), note)
+		b.WriteString("| Listing | Location | Price/night |\n|---|---|---:|\n")
+		for _, l := range report.Outliers {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", mdLink(l.Title, l.URL), mdEscape(locationKey(l)),
+				formatMoney(l.Price, cur))
+		}
+	}
+
+	if len(report.TopRated) > 0 {
+		fmt.Fprintf(&b, "\n## Top %d Highest Rated Properties\n\n", len(report.TopRated))
+		b.WriteString("| # | Listing | Rating | Reviews | Score |\n|---:|---|---:|---:|---:|\n")
+		for i, r := range report.TopRated {
+			fmt.Fprintf(&b, "| %d | %s | %.2f | %d | %.2f |\n", i+1, mdLink(r.Listing.Title, r.Listing.URL),
+				r.Listing.Rating, r.Listing.ReviewCount, r.Score)
+		}
+	}
+
+	if len(report.BestValue) > 0 {
+		b.WriteString("\n## Best Value by Location\n\nValue score 0-100: price against the local median, rating, reviews and beds.\n\n")
+		b.WriteString("| Location | # | Listing | Price/night | Rating | Score |\n|---|---:|---|---:|---:|---:|\n")
+		for _, lr := range report.BestValue {
+			for i, r := range lr.Listings {
+				fmt.Fprintf(&b, "| %s | %d | %s | %s | %.2f | %.1f |\n", mdEscape(lr.Location), i+1,
+					mdLink(r.Listing.Title, r.Listing.URL), formatMoney(r.Listing.Price, cur), r.Listing.Rating, r.Score)
+			}
+		}
+	}
+
+	if t := report.Trends; t != nil {
+		fmt.Fprintf(&b, "\n## Trends\n\n%d runs in the last %d days, compared with run `%s` (%s).\n\n",
+			t.Runs, t.WindowDays, t.BaselineRunID, t.BaselineAt.Format("2006-01-02"))
+		b.WriteString("| Location | Median | Change | Rating | Drift | New | Gone |\n|---|---:|---:|---:|---:|---:|---:|\n")
+		for _, lt := range t.Locations {
+			change, drift := "-", "-"
+			if lt.BaselineMedian > 0 && lt.MedianPrice > 0 {
+				change = fmt.Sprintf("%+.1f%%", lt.MedianChangePct)
+			}
+			if lt.BaselineRating > 0 && lt.AverageRating > 0 {
+				drift = fmt.Sprintf("%+.2f", lt.RatingDrift)
+			}
+			fmt.Fprintf(&b, "| %s | %.2f | %s | %.2f | %s | %d | %d |\n", mdEscape(lt.Location), lt.MedianPrice,
+				change, lt.AverageRating, drift, lt.New, lt.Disappeared)
+		}
+		if len(t.PriceChanges) > 0 {
+			b.WriteString("\n### Price Changes\n\n| Listing | Location | Old | New | Change |\n|---|---|---:|---:|---:|\n")
+			for _, c := range t.PriceChanges {
+				fmt.Fprintf(&b, "| %s | %s | %s | %s | %+.1f%% |\n", mdEscape(c.Title), mdEscape(c.Location),
+					formatMoney(c.OldPrice, cur), formatMoney(c.NewPrice, cur), c.ChangePct)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func mdCounts(b *strings.Builder, title, keyLabel string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n## %s\n\n| %s | Listings |\n|---|---:|\n", title, keyLabel)
+	for _, r := range sortedCounts(counts) {
+		fmt.Fprintf(b, "| %s | %d |\n", mdEscape(r.key), r.count)
+	}
+}
+
+func mdGroupStats(b *strings.Builder, title, keyLabel string, stats []*models.GroupStats, currency string) {
+	if len(stats) == 0 {
+		return
+	}
+	if currency == "" {
+		currency = "USD"
+	}
+	fmt.Fprintf(b, "\n## %s (nightly, %s)\n\n", title, currency)
+	fmt.Fprintf(b, "| %s | N | P10 | P25 | Median | P75 | P90 | Mean | Rating | Superhosts |\n", keyLabel)
+	b.WriteString("|---|---:|---:|---:|---:|---:|---:|---:|---:|---:|\n")
+	for _, g := range stats {
+		rating := "-"
+		if g.RatedCount > 0 {
+			rating = fmt.Sprintf("%.2f", g.AverageRating)
+		}
+		if g.PricedCount == 0 {
+			fmt.Fprintf(b, "| %s | %d | - | - | - | - | - | - | %s | %.0f%% |\n",
+				mdEscape(g.Key), g.Count, rating, g.SuperhostShare*100)
+			continue
+		}
+		fmt.Fprintf(b, "| %s | %d | %.2f | %.2f | %.2f | %.2f | %.2f | %.2f | %s | %.0f%% |\n",
+			mdEscape(g.Key), g.Count, g.P10Price, g.P25Price, g.MedianPrice, g.P75Price, g.P90Price,
+			g.MeanPrice, rating, g.SuperhostShare*100)
+	}
+}
+
+// mdEscaper keeps listing titles from breaking tables and links
+var mdEscaper = strings.NewReplacer("|", `\|`, "[", `\[`, "]", `\]`, "\n", " ")
+
+func mdEscape(s string) string {
+	return mdEscaper.Replace(s)
+}
+
+func mdLink(text, url string) string {
+	if url == "" {
+		return mdEscape(text)
+	}
+	return fmt.Sprintf("[%s](%s)", mdEscape(text), url)
+}
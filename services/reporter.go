@@ -1,65 +1,290 @@
 package services
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strings"
 
 	"airbnb-scraper/models"
 )
 
+// Report formats
+const (
+	ReportFormatText     = "text"
+	ReportFormatJSON     = "json"
+	ReportFormatMarkdown = "markdown"
+	ReportFormatHTML     = "html"
+)
+
+// Reporter renders an insight report in one format
+type Reporter interface {
+	Render(w io.Writer, report *models.InsightReport) error
+}
+
+// NewReporter returns the reporter for a format name
+func NewReporter(format string) (Reporter, error) {
+	switch strings.ToLower(format) {
+	case ReportFormatText, "":
+		return TextReporter{}, nil
+	case ReportFormatJSON:
+		return JSONReporter{}, nil
+	case ReportFormatMarkdown, "md":
+		return MarkdownReporter{}, nil
+	case ReportFormatHTML:
+		return HTMLReporter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown report format %q (available: text, json, markdown, html)", format)
+	}
+}
+
 // PrintInsightReport formats and prints the insight report to terminal
 func PrintInsightReport(report *models.InsightReport) {
+	_ = TextReporter{}.Render(os.Stdout, report)
+}
+
+// JSONReporter renders the report as indented JSON
+type JSONReporter struct{}
+
+func (JSONReporter) Render(w io.Writer, report *models.InsightReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// TextReporter renders the report as the box-drawing terminal layout
+type TextReporter struct{}
+
+func (TextReporter) Render(w io.Writer, report *models.InsightReport) error {
+	var b strings.Builder
+	renderText(&b, report)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func renderText(w io.Writer, report *models.InsightReport) {
 	border := strings.Repeat("═", 55)
 	thin := strings.Repeat("─", 55)
 
-	fmt.Printf("\n╔%s╗\n", border)
-	fmt.Printf("║%s║\n", center("VACATION RENTAL MARKET INSIGHTS ", 55))
-	fmt.Printf("╚%s╝\n", border)
+	fmt.Fprintf(w, "\n╔%s╗\n", border)
+	fmt.Fprintf(w, "║%s║\n", center("VACATION RENTAL MARKET INSIGHTS ", 55))
+	fmt.Fprintf(w, "╚%s╝\n", border)
 
-	fmt.Printf("\n OVERVIEW\n%s\n", thin)
-	fmt.Printf("  Total Listings Scraped  : %d\n", report.TotalListings)
-	fmt.Printf("  Airbnb Listings         : %d\n", report.AirbnbListings)
-	fmt.Printf("  Average Price/Night     : $%.2f\n", report.AveragePrice)
-	fmt.Printf("  Minimum Price/Night     : $%.2f\n", report.MinPrice)
-	fmt.Printf("  Maximum Price/Night     : $%.2f\n", report.MaxPrice)
+	fmt.Fprintf(w, "\n OVERVIEW\n%s\n", thin)
+	fmt.Fprintf(w, "  Total Listings Scraped  : %d\n", report.TotalListings)
+	fmt.Fprintf(w, "  Airbnb Listings         : %d\n", report.AirbnbListings)
+	if report.CollapsedDuplicates > 0 {
+		fmt.Fprintf(w, "  Near-Duplicates Hidden  : %d\n", report.CollapsedDuplicates)
+	}
+	fmt.Fprintf(w, "  Average Price/Night     : %s\n", formatMoney(report.AveragePrice, report.Currency))
+	fmt.Fprintf(w, "  Minimum Price/Night     : %s\n", formatMoney(report.MinPrice, report.Currency))
+	fmt.Fprintf(w, "  Maximum Price/Night     : %s\n", formatMoney(report.MaxPrice, report.Currency))
 
 	if report.MostExpensive != nil {
-		fmt.Printf("\n MOST EXPENSIVE PROPERTY\n%s\n", thin)
-		fmt.Printf("  Title    : %s\n", report.MostExpensive.Title)
-		fmt.Printf("  Price    : $%.2f/night\n", report.MostExpensive.Price)
-		fmt.Printf("  Location : %s\n", report.MostExpensive.Location)
-		fmt.Printf("  URL      : %s\n", report.MostExpensive.URL)
-	}
-
-	if len(report.ListingsByLocation) > 0 {
-		fmt.Printf("\n LISTINGS PER LOCATION\n%s\n", thin)
-		// Sort by count descending
-		type locCount struct {
-			loc   string
-			count int
+		fmt.Fprintf(w, "\n MOST EXPENSIVE PROPERTY\n%s\n", thin)
+		fmt.Fprintf(w, "  Title    : %s\n", report.MostExpensive.Title)
+		fmt.Fprintf(w, "  Price    : %s/night\n", formatMoney(report.MostExpensive.Price, report.Currency))
+		fmt.Fprintf(w, "  Location : %s\n", locationKey(report.MostExpensive))
+		fmt.Fprintf(w, "  URL      : %s\n", report.MostExpensive.URL)
+	}
+
+	printCounts(w, "LISTINGS PER LOCATION", report.ListingsByLocation, thin)
+
+	byType := make(map[string]int, len(report.ListingsByType))
+	for t, cnt := range report.ListingsByType {
+		byType[t.Label()] += cnt
+	}
+	printCounts(w, "LISTINGS PER PROPERTY TYPE", byType, thin)
+
+	printGroupStats(w, "MARKET BY LOCATION", "Location", report.LocationStats, report.Currency, thin)
+	printGroupStats(w, "MARKET BY PROPERTY TYPE", "Type", report.TypeStats, report.Currency, thin)
+
+	printDistributions(w, report, thin)
+
+	printOutliers(w, report, thin)
+
+	if len(report.TopRated) > 0 {
+		fmt.Fprintf(w, "\n TOP %d HIGHEST RATED PROPERTIES\n%s\n", len(report.TopRated), thin)
+		for i, r := range report.TopRated {
+			fmt.Fprintf(w, "  %d. %-35s %.2f  (%d reviews, score %.2f)\n",
+				i+1, truncate(r.Listing.Title, 35), r.Listing.Rating, r.Listing.ReviewCount, r.Score)
 		}
-		var locs []locCount
-		for loc, cnt := range report.ListingsByLocation {
-			locs = append(locs, locCount{loc, cnt})
+	}
+
+	if len(report.BestValue) > 0 {
+		fmt.Fprintf(w, "\n BEST VALUE BY LOCATION (score 0-100)\n%s\n", thin)
+		for _, lr := range report.BestValue {
+			fmt.Fprintf(w, "  %s\n", lr.Location)
+			for i, r := range lr.Listings {
+				fmt.Fprintf(w, "    %d. %-33s %10s  %5.1f\n",
+					i+1, truncate(r.Listing.Title, 33), formatMoney(r.Listing.Price, report.Currency), r.Score)
+			}
 		}
-		sort.Slice(locs, func(i, j int) bool {
-			return locs[i].count > locs[j].count
-		})
-		for _, lc := range locs {
-			bar := strings.Repeat("▓", lc.count)
-			fmt.Printf("  %-25s %3d  %s\n", lc.loc+":", lc.count, bar)
+	}
+
+	printTrends(w, report.Trends, report.Currency, thin)
+
+	fmt.Fprintf(w, "\n%s\n\n", border)
+}
+
+// PrintValidationSummary prints how many records passed validation and how
+// often each rule fired during the run
+func PrintValidationSummary(stats ValidationStats) {
+	thin := strings.Repeat("─", 55)
+	fmt.Printf("\n VALIDATION\n%s\n", thin)
+	fmt.Printf("  Accepted   : %d\n", stats.Accepted)
+	fmt.Printf("  Warned     : %d\n", stats.Warned)
+	fmt.Printf("  Rejected   : %d (quarantined)\n", stats.Rejected)
+	fmt.Printf("  Duplicates : %d\n", stats.Duplicates)
+
+	if len(stats.RuleHits) == 0 {
+		return
+	}
+	codes := make([]string, 0, len(stats.RuleHits))
+	for code := range stats.RuleHits {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if stats.RuleHits[codes[i]] != stats.RuleHits[codes[j]] {
+			return stats.RuleHits[codes[i]] > stats.RuleHits[codes[j]]
 		}
+		return codes[i] < codes[j]
+	})
+	fmt.Println("  Rule hits:")
+	for _, code := range codes {
+		fmt.Printf("    %-25s %d\n", code+":", stats.RuleHits[code])
 	}
+}
 
-	if len(report.TopRated) > 0 {
-		fmt.Printf("\n TOP %d HIGHEST RATED PROPERTIES\n%s\n", len(report.TopRated), thin)
-		for i, l := range report.TopRated {
-			fmt.Printf("  %d. %-35s %.2f \n", i+1, truncate(l.Title, 35), l.Rating)
+// printGroupStats prints nightly price percentiles, average rating and
+// superhost share per group as a table
+func printGroupStats(w io.Writer, title, keyLabel string, stats []*models.GroupStats, currency, thin string) {
+	if len(stats) == 0 {
+		return
+	}
+	if currency == "" {
+		currency = "USD"
+	}
+	fmt.Fprintf(w, "\n %s (nightly, %s)\n%s\n", title, currency, thin)
+	fmt.Fprintf(w, "  %-16s %4s %8s %8s %8s %8s %8s %8s %6s %5s\n",
+		keyLabel, "N", "P10", "P25", "Median", "P75", "P90", "Mean", "Rating", "SH%")
+	for _, g := range stats {
+		rating := "-"
+		if g.RatedCount > 0 {
+			rating = fmt.Sprintf("%.2f", g.AverageRating)
+		}
+		if g.PricedCount == 0 {
+			fmt.Fprintf(w, "  %-16s %4d %8s %8s %8s %8s %8s %8s %6s %4.0f%%\n",
+				truncate(g.Key, 16), g.Count, "-", "-", "-", "-", "-", "-", rating, g.SuperhostShare*100)
+			continue
+		}
+		fmt.Fprintf(w, "  %-16s %4d %8.2f %8.2f %8.2f %8.2f %8.2f %8.2f %6s %4.0f%%\n",
+			truncate(g.Key, 16), g.Count, g.P10Price, g.P25Price, g.MedianPrice, g.P75Price, g.P90Price,
+			g.MeanPrice, rating, g.SuperhostShare*100)
+	}
+}
+
+// printOutliers lists flagged price outliers next to their location's median
+// so they can be reviewed by hand
+func printOutliers(w io.Writer, report *models.InsightReport, thin string) {
+	if len(report.Outliers) == 0 {
+		return
+	}
+	medians := make(map[string]float64, len(report.LocationStats))
+	for _, g := range report.LocationStats {
+		medians[g.Key] = g.MedianPrice
+	}
+	note := "included in"
+	if report.OutliersExcluded {
+		note = "excluded from"
+	}
+	fmt.Fprintf(w, "\n PRICE OUTLIERS (%d, %s price figures)\n%s\n", len(report.Outliers), note, thin)
+	for _, l := range report.Outliers {
+		loc := locationKey(l)
+		fmt.Fprintf(w, "  %-30s %-14s %12s  (median %s)\n", truncate(l.Title, 30), truncate(loc, 14),
+			formatMoney(l.Price, report.Currency), formatMoney(medians[loc], report.Currency))
+		fmt.Fprintf(w, "     %s\n", l.URL)
+	}
+}
+
+// printTrends prints per-location movement since the baseline run and the
+// largest price changes since each listing's previous snapshot
+func printTrends(w io.Writer, t *models.TrendReport, currency, thin string) {
+	if t == nil {
+		return
+	}
+	fmt.Fprintf(w, "\n TRENDS (%d runs in the last %d days)\n%s\n", t.Runs, t.WindowDays, thin)
+	fmt.Fprintf(w, "  Compared with run %s (%s)\n", t.BaselineRunID, t.BaselineAt.Format("2006-01-02"))
+	fmt.Fprintf(w, "  %-16s %9s %8s %7s %6s %5s %5s\n", "Location", "Median", "Change", "Rating", "Drift", "New", "Gone")
+	for _, lt := range t.Locations {
+		change, drift := "-", "-"
+		if lt.BaselineMedian > 0 && lt.MedianPrice > 0 {
+			change = fmt.Sprintf("%+.1f%%", lt.MedianChangePct)
 		}
+		if lt.BaselineRating > 0 && lt.AverageRating > 0 {
+			drift = fmt.Sprintf("%+.2f", lt.RatingDrift)
+		}
+		fmt.Fprintf(w, "  %-16s %9.2f %8s %7.2f %6s %5d %5d\n", truncate(lt.Location, 16), lt.MedianPrice,
+			change, lt.AverageRating, drift, lt.New, lt.Disappeared)
+	}
+
+	if len(t.PriceChanges) == 0 {
+		return
 	}
+	fmt.Fprintf(w, "\n PRICE CHANGES\n%s\n", thin)
+	for _, c := range t.PriceChanges {
+		fmt.Fprintf(w, "  %-30s %12s → %-12s %+6.1f%%\n", truncate(c.Title, 30),
+			formatMoney(c.OldPrice, currency), formatMoney(c.NewPrice, currency), c.ChangePct)
+		fmt.Fprintf(w, "     %s, since %s\n", c.Location, c.Since.Format("2006-01-02"))
+	}
+}
+
+// printCounts prints a section of counts as bars, sorted by count descending
+func printCounts(w io.Writer, title string, counts map[string]int, thin string) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n %s\n%s\n", title, thin)
+	for _, r := range sortedCounts(counts) {
+		bar := strings.Repeat("▓", r.count)
+		fmt.Fprintf(w, "  %-25s %3d  %s\n", r.key+":", r.count, bar)
+	}
+}
 
-	fmt.Printf("\n%s\n\n", border)
+type keyCount struct {
+	key   string
+	count int
+}
+
+// sortedCounts orders counts by count descending, then by key
+func sortedCounts(counts map[string]int) []keyCount {
+	rows := make([]keyCount, 0, len(counts))
+	for key, cnt := range counts {
+		rows = append(rows, keyCount{key, cnt})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].count != rows[j].count {
+			return rows[i].count > rows[j].count
+		}
+		return rows[i].key < rows[j].key
+	})
+	return rows
+}
+
+// formatMoney renders an amount with its currency symbol, e.g. "$52.30" or "THB 1840.00"
+func formatMoney(amount float64, currency string) string {
+	switch currency {
+	case "", "USD":
+		return fmt.Sprintf("$%.2f", amount)
+	case "EUR":
+		return fmt.Sprintf("€%.2f", amount)
+	case "GBP":
+		return fmt.Sprintf("£%.2f", amount)
+	default:
+		return fmt.Sprintf("%s %.2f", currency, amount)
+	}
 }
 
 func center(s string, width int) string {
@@ -78,4 +303,4 @@ func truncate(s string, max int) string {
 		return s
 	}
 	return string(runes[:max-3]) + "..."
-}
\ No newline at end of file
+}
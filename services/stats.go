@@ -0,0 +1,93 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"airbnb-scraper/models"
+)
+
+// groupStats computes price and rating statistics for groups of listings,
+// returned largest group first. priced selects the listings whose price counts.
+func groupStats(listings []*models.Listing, key func(l *models.Listing) string, priced func(l *models.Listing) bool) []*models.GroupStats {
+	groups := make(map[string][]*models.Listing)
+	for _, l := range listings {
+		if k := key(l); k != "" {
+			groups[k] = append(groups[k], l)
+		}
+	}
+
+	stats := make([]*models.GroupStats, 0, len(groups))
+	for k, members := range groups {
+		stats = append(stats, summarize(k, members, priced))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Count != stats[j].Count {
+			return stats[i].Count > stats[j].Count
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	return stats
+}
+
+// summarize computes the statistics of one group. Listings without a usable
+// price (total-only cards, failed conversions, excluded outliers) are left out
+// of the price figures instead of dragging them toward zero.
+func summarize(key string, listings []*models.Listing, priced func(l *models.Listing) bool) *models.GroupStats {
+	g := &models.GroupStats{Key: key, Count: len(listings)}
+
+	var prices []float64
+	var ratingSum float64
+	superhosts := 0
+	for _, l := range listings {
+		if priced(l) {
+			prices = append(prices, l.Price)
+		}
+		if l.Rating > 0 {
+			g.RatedCount++
+			ratingSum += l.Rating
+		}
+		if l.IsSuperhost {
+			superhosts++
+		}
+	}
+
+	if len(prices) > 0 {
+		sort.Float64s(prices)
+		g.PricedCount = len(prices)
+		g.MeanPrice = mean(prices)
+		g.MedianPrice = percentile(prices, 50)
+		g.P10Price = percentile(prices, 10)
+		g.P25Price = percentile(prices, 25)
+		g.P75Price = percentile(prices, 75)
+		g.P90Price = percentile(prices, 90)
+	}
+	if g.RatedCount > 0 {
+		g.AverageRating = ratingSum / float64(g.RatedCount)
+	}
+	g.SuperhostShare = float64(superhosts) / float64(g.Count)
+	return g
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// percentile returns the p-th percentile (0-100) of sorted values, linearly
+// interpolating between the two nearest ranks
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"airbnb-scraper/models"
+)
+
+// trendBaselineAge is how far back the baseline run should be for a
+// week-over-week comparison
+const trendBaselineAge = 7 * 24 * time.Hour
+
+// runSnapshots is all snapshots of one run
+type runSnapshots struct {
+	id        string
+	at        time.Time // earliest scrape of the run
+	byListing map[string]*models.Snapshot
+}
+
+// Trends compares the latest run in snapshots with earlier ones. The baseline
+// is the latest run at least a week older than the current one, or the oldest
+// run in the window when history is shorter than a week. Returns nil when
+// there are fewer than two runs.
+func (s *InsightService) Trends(snapshots []*models.Snapshot) *models.TrendReport {
+	runs := groupRuns(snapshots)
+	if len(runs) < 2 {
+		return nil
+	}
+	current, previous := runs[len(runs)-1], runs[len(runs)-2]
+	baseline := runs[0]
+	for _, r := range runs[:len(runs)-1] {
+		if current.at.Sub(r.at) >= trendBaselineAge {
+			baseline = r
+		}
+	}
+
+	report := &models.TrendReport{
+		WindowDays:    s.cfg.TrendWindowDays,
+		Runs:          len(runs),
+		CurrentRunID:  current.id,
+		CurrentAt:     current.at,
+		BaselineRunID: baseline.id,
+		BaselineAt:    baseline.at,
+	}
+
+	// Listings seen in any run before the current one
+	seenBefore := make(map[string]bool)
+	for _, r := range runs[:len(runs)-1] {
+		for id := range r.byListing {
+			seenBefore[id] = true
+		}
+	}
+
+	trends := make(map[string]*models.LocationTrend)
+	trend := func(loc string) *models.LocationTrend {
+		t, ok := trends[loc]
+		if !ok {
+			t = &models.LocationTrend{Location: loc}
+			trends[loc] = t
+		}
+		return t
+	}
+	for id, snap := range current.byListing {
+		if !seenBefore[id] {
+			trend(snap.City).New++
+		}
+	}
+	for id, snap := range previous.byListing {
+		if _, ok := current.byListing[id]; !ok {
+			trend(snap.City).Disappeared++
+		}
+	}
+
+	curPrices, curRatings := s.locationValues(current)
+	basePrices, baseRatings := s.locationValues(baseline)
+	for loc := range curPrices {
+		trend(loc)
+	}
+	for loc, t := range trends {
+		t.MedianPrice = median(curPrices[loc])
+		t.BaselineMedian = median(basePrices[loc])
+		if t.MedianPrice > 0 && t.BaselineMedian > 0 {
+			t.MedianChangePct = (t.MedianPrice - t.BaselineMedian) / t.BaselineMedian * 100
+		}
+		t.AverageRating = mean(curRatings[loc])
+		t.BaselineRating = mean(baseRatings[loc])
+		if t.AverageRating > 0 && t.BaselineRating > 0 {
+			t.RatingDrift = t.AverageRating - t.BaselineRating
+		}
+		report.Locations = append(report.Locations, t)
+	}
+	sort.Slice(report.Locations, func(i, j int) bool {
+		return report.Locations[i].Location < report.Locations[j].Location
+	})
+
+	report.PriceChanges = priceChanges(runs, s.cfg.TrendFeedLimit)
+	return report
+}
+
+// groupRuns splits snapshots by run, ordered by when each run started
+func groupRuns(snapshots []*models.Snapshot) []*runSnapshots {
+	byID := make(map[string]*runSnapshots)
+	var runs []*runSnapshots
+	for _, snap := range snapshots {
+		r, ok := byID[snap.RunID]
+		if !ok {
+			r = &runSnapshots{id: snap.RunID, at: snap.ScrapedAt, byListing: make(map[string]*models.Snapshot)}
+			byID[snap.RunID] = r
+			runs = append(runs, r)
+		}
+		if snap.ScrapedAt.Before(r.at) {
+			r.at = snap.ScrapedAt
+		}
+		r.byListing[snap.ListingID] = snap
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].at.Before(runs[j].at) })
+	return runs
+}
+
+// locationValues collects the usable prices and the ratings of a run per location
+func (s *InsightService) locationValues(r *runSnapshots) (prices, ratings map[string][]float64) {
+	prices = make(map[string][]float64)
+	ratings = make(map[string][]float64)
+	for _, snap := range r.byListing {
+		if snap.Price > 0 && !(s.cfg.ExcludeOutliers && snap.IsOutlier) {
+			prices[snap.City] = append(prices[snap.City], snap.Price)
+		}
+		if snap.Rating > 0 {
+			ratings[snap.City] = append(ratings[snap.City], snap.Rating)
+		}
+	}
+	return prices, ratings
+}
+
+// priceChanges lists listings of the latest run whose price differs from
+// their most recent earlier snapshot, largest relative change first
+func priceChanges(runs []*runSnapshots, limit int) []*models.PriceChange {
+	current := runs[len(runs)-1]
+	var changes []*models.PriceChange
+	for id, snap := range current.byListing {
+		if snap.Price <= 0 {
+			continue
+		}
+		for i := len(runs) - 2; i >= 0; i-- {
+			old, ok := runs[i].byListing[id]
+			if !ok || old.Price <= 0 {
+				continue
+			}
+			// Stored prices have two decimals; anything smaller is rounding
+			if math.Abs(snap.Price-old.Price) >= 0.01 {
+				changes = append(changes, &models.PriceChange{
+					ListingID: id,
+					Title:     snap.Title,
+					Location:  snap.City,
+					OldPrice:  old.Price,
+					NewPrice:  snap.Price,
+					Change:    snap.Price - old.Price,
+					ChangePct: (snap.Price - old.Price) / old.Price * 100,
+					Since:     old.ScrapedAt,
+				})
+			}
+			break
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := math.Abs(changes[i].ChangePct), math.Abs(changes[j].ChangePct)
+		if a != b {
+			return a > b
+		}
+		return changes[i].ListingID < changes[j].ListingID
+	})
+	if limit > 0 && len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes
+}
+
+// median returns the median of unsorted values
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return percentile(sorted, 50)
+}
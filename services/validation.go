@@ -0,0 +1,243 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"unicode/utf8"
+
+	"airbnb-scraper/models"
+)
+
+// Rule checks
+const (
+	CheckRequired  = "required"   // string not empty, number not zero
+	CheckRange     = "range"      // min <= number <= max; zero is left to "required"
+	CheckPattern   = "pattern"    // string matches a regular expression; empty is left to "required"
+	CheckMaxLength = "max_length" // at most max characters
+	CheckMinLength = "min_length" // at least min characters; empty is left to "required"
+)
+
+// ValidationRule is one declarative check from the rules file
+type ValidationRule struct {
+	Code     string   `json:"code"`
+	Field    string   `json:"field"`
+	Check    string   `json:"check"`
+	Severity string   `json:"severity"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Pattern  string   `json:"pattern,omitempty"`
+	Currency string   `json:"currency,omitempty"` // only listings priced in this currency
+	Unless   string   `json:"unless,omitempty"`   // boolean field that exempts a listing, e.g. "is_new"
+	Message  string   `json:"message,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// validationFields exposes the Listing fields rules can refer to, by their
+// column names. Values are either string, float64 or bool.
+var validationFields = map[string]func(l *models.Listing) interface{}{
+	"listing_id":        func(l *models.Listing) interface{} { return l.ListingID },
+	"title":             func(l *models.Listing) interface{} { return l.Title },
+	"url":               func(l *models.Listing) interface{} { return l.URL },
+	"description":       func(l *models.Listing) interface{} { return l.Description },
+	"description_clean": func(l *models.Listing) interface{} { return l.DescriptionClean },
+	"description_lang":  func(l *models.Listing) interface{} { return l.DescriptionLang },
+	"location":          func(l *models.Listing) interface{} { return l.Location },
+	"city":              func(l *models.Listing) interface{} { return l.City },
+	"currency":          func(l *models.Listing) interface{} { return l.Currency },
+	"price":             func(l *models.Listing) interface{} { return l.Price },
+	"local_price":       func(l *models.Listing) interface{} { return l.LocalPrice },
+	"total_price":       func(l *models.Listing) interface{} { return l.TotalPrice },
+	"discount_pct":      func(l *models.Listing) interface{} { return l.DiscountPct },
+	"rating":            func(l *models.Listing) interface{} { return l.Rating },
+	"review_count":      func(l *models.Listing) interface{} { return float64(l.ReviewCount) },
+	"beds":              func(l *models.Listing) interface{} { return float64(l.Beds) },
+	"bedrooms":          func(l *models.Listing) interface{} { return float64(l.Bedrooms) },
+	"is_new":            func(l *models.Listing) interface{} { return l.IsNew },
+}
+
+// Validator classifies clean listings as accepted, warned or rejected
+type Validator struct {
+	rules []*ValidationRule
+}
+
+// LoadValidator reads and checks a JSON rules file of the form {"rules": [...]}.
+// Several rules may share a code, e.g. one price range per currency.
+func LoadValidator(path string) (*Validator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation rules: %w", err)
+	}
+	var file struct {
+		Rules []*ValidationRule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse validation rules %s: %w", path, err)
+	}
+	for i, r := range file.Rules {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("validation rule %d (%s) in %s: %w", i+1, r.Code, path, err)
+		}
+	}
+	return &Validator{rules: file.Rules}, nil
+}
+
+// compile checks a rule for mistakes so a typo fails at startup rather than
+// silently passing every listing
+func (r *ValidationRule) compile() error {
+	if r.Code == "" {
+		return fmt.Errorf("code is required")
+	}
+	if _, ok := validationFields[r.Field]; !ok {
+		return fmt.Errorf("unknown field %q", r.Field)
+	}
+	if r.Unless != "" {
+		if _, ok := validationFields[r.Unless]; !ok {
+			return fmt.Errorf("unknown unless field %q", r.Unless)
+		}
+	}
+	if r.Severity != models.SeverityWarn && r.Severity != models.SeverityReject {
+		return fmt.Errorf("severity must be %q or %q", models.SeverityWarn, models.SeverityReject)
+	}
+	switch r.Check {
+	case CheckRequired:
+	case CheckRange:
+		if r.Min == nil && r.Max == nil {
+			return fmt.Errorf("range needs min or max")
+		}
+	case CheckMaxLength:
+		if r.Max == nil {
+			return fmt.Errorf("max_length needs max")
+		}
+	case CheckMinLength:
+		if r.Min == nil {
+			return fmt.Errorf("min_length needs min")
+		}
+	case CheckPattern:
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		r.re = re
+	default:
+		return fmt.Errorf("unknown check %q", r.Check)
+	}
+	return nil
+}
+
+// Validate runs every rule against l and returns its status and the failed rules
+func (v *Validator) Validate(l *models.Listing) (string, []models.ValidationIssue) {
+	status := models.ValidationAccepted
+	var issues []models.ValidationIssue
+	for _, r := range v.rules {
+		if r.Currency != "" && r.Currency != l.Currency {
+			continue
+		}
+		if r.Unless != "" {
+			if b, ok := validationFields[r.Unless](l).(bool); ok && b {
+				continue
+			}
+		}
+		msg, failed := r.apply(validationFields[r.Field](l))
+		if !failed {
+			continue
+		}
+		if r.Message != "" {
+			msg = r.Message
+		}
+		issues = append(issues, models.ValidationIssue{
+			Code: r.Code, Field: r.Field, Severity: r.Severity, Message: msg,
+		})
+		if r.Severity == models.SeverityReject {
+			status = models.ValidationRejected
+		} else if status == models.ValidationAccepted {
+			status = models.ValidationWarned
+		}
+	}
+	return status, issues
+}
+
+// apply runs the rule's check on a field value and describes a failure
+func (r *ValidationRule) apply(value interface{}) (string, bool) {
+	switch r.Check {
+	case CheckRequired:
+		switch v := value.(type) {
+		case string:
+			return r.Field + " is empty", v == ""
+		case float64:
+			return r.Field + " is zero", v == 0
+		case bool:
+			return r.Field + " is false", !v
+		}
+	case CheckRange:
+		v, ok := value.(float64)
+		if !ok || v == 0 {
+			return "", false
+		}
+		if (r.Min != nil && v < *r.Min) || (r.Max != nil && v > *r.Max) {
+			return fmt.Sprintf("%s %g outside %s", r.Field, v, r.bounds()), true
+		}
+	case CheckPattern:
+		v, ok := value.(string)
+		if ok && v != "" && !r.re.MatchString(v) {
+			return fmt.Sprintf("%s %q does not match %s", r.Field, v, r.Pattern), true
+		}
+	case CheckMaxLength:
+		if v, ok := value.(string); ok && float64(utf8.RuneCountInString(v)) > *r.Max {
+			return fmt.Sprintf("%s longer than %g characters", r.Field, *r.Max), true
+		}
+	case CheckMinLength:
+		v, ok := value.(string)
+		if ok && v != "" && float64(utf8.RuneCountInString(v)) < *r.Min {
+			return fmt.Sprintf("%s shorter than %g characters", r.Field, *r.Min), true
+		}
+	}
+	return "", false
+}
+
+func (r *ValidationRule) bounds() string {
+	switch {
+	case r.Min != nil && r.Max != nil:
+		return fmt.Sprintf("[%g, %g]", *r.Min, *r.Max)
+	case r.Min != nil:
+		return fmt.Sprintf("[%g, ∞)", *r.Min)
+	default:
+		return fmt.Sprintf("(-∞, %g]", *r.Max)
+	}
+}
+
+// ValidationStats counts validation outcomes and rule hits for one Clean call
+type ValidationStats struct {
+	Accepted   int
+	Warned     int
+	Rejected   int
+	Duplicates int
+	RuleHits   map[string]int // rule code → listings that failed it
+}
+
+// Add adds the counts of o, such as the stats of another run
+func (s *ValidationStats) Add(o ValidationStats) {
+	s.Accepted += o.Accepted
+	s.Warned += o.Warned
+	s.Rejected += o.Rejected
+	s.Duplicates += o.Duplicates
+	for code, n := range o.RuleHits {
+		s.RuleHits[code] += n
+	}
+}
+
+func (s *ValidationStats) record(status string, issues []models.ValidationIssue) {
+	switch status {
+	case models.ValidationAccepted:
+		s.Accepted++
+	case models.ValidationWarned:
+		s.Warned++
+	case models.ValidationRejected:
+		s.Rejected++
+	}
+	for _, is := range issues {
+		s.RuleHits[is.Code]++
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"math"
+	"sort"
+
+	"airbnb-scraper/models"
+)
+
+// valueFullBeds is the bed count that earns the full capacity part of the
+// value score
+const valueFullBeds = 4
+
+// ScoreValue sets ValueScore on every listing: a 0-100 weighted blend of
+// how cheap it is against the median price of its location, its
+// review-weighted rating, how many reviews back that rating and, when the
+// card shows them, how many beds it has. Parts a listing has no data for are
+// left out and the other weights rescaled. Listings without a usable price
+// score 0. Call it after FlagOutliers and before storing the listings so the
+// score is persisted.
+func (s *InsightService) ScoreValue(listings []*models.Listing) {
+	prices := make(map[string][]float64)
+	for _, l := range listings {
+		if s.hasUsablePrice(l) {
+			prices[locationKey(l)] = append(prices[locationKey(l)], l.Price)
+		}
+	}
+	medians := make(map[string]float64, len(prices))
+	for loc, p := range prices {
+		medians[loc] = median(p)
+	}
+
+	mean, rated := meanRating(listings)
+	prior := float64(s.cfg.RatingPriorReviews)
+	saturation := math.Log1p(math.Max(1, float64(s.cfg.ValueReviewSaturation)))
+
+	scored := 0
+	for _, l := range listings {
+		l.ValueScore = 0
+		med := medians[locationKey(l)]
+		if !s.hasUsablePrice(l) || med <= 0 {
+			continue
+		}
+
+		var sum, weights float64
+		add := func(weight, part float64) {
+			sum += weight * clamp01(part)
+			weights += weight
+		}
+		// At the median a listing earns half the price part, at twice the
+		// median none of it
+		add(s.cfg.ValueWeightPrice, 1-l.Price/med/2)
+		if rated {
+			add(s.cfg.ValueWeightRating, (weightedRating(l, mean, prior)-1)/4)
+		}
+		add(s.cfg.ValueWeightReviews, math.Log1p(float64(l.ReviewCount))/saturation)
+		if l.Beds > 0 {
+			add(s.cfg.ValueWeightCapacity, float64(l.Beds)/valueFullBeds)
+		}
+
+		if weights > 0 {
+			l.ValueScore = math.Round(sum/weights*100*100) / 100
+			scored++
+		}
+	}
+	s.logger.Debug("Scored the value of %d listings", scored)
+}
+
+// bestValue ranks the scored listings of each location by value score and
+// keeps the first n, in the order of stats
+func (s *InsightService) bestValue(stats []*models.GroupStats, listings []*models.Listing, n int) []*models.LocationRanking {
+	byLocation := make(map[string][]*models.RankedListing)
+	for _, l := range listings {
+		if l.ValueScore > 0 && s.hasUsablePrice(l) && locationKey(l) != "" {
+			byLocation[locationKey(l)] = append(byLocation[locationKey(l)],
+				&models.RankedListing{Listing: l, Score: l.ValueScore})
+		}
+	}
+
+	var rankings []*models.LocationRanking
+	for _, g := range stats {
+		ranked := byLocation[g.Key]
+		if len(ranked) == 0 {
+			continue
+		}
+		sort.SliceStable(ranked, func(i, j int) bool {
+			return ranked[i].Score > ranked[j].Score
+		})
+		if len(ranked) > n {
+			ranked = ranked[:n]
+		}
+		rankings = append(rankings, &models.LocationRanking{Location: g.Key, Listings: ranked})
+	}
+	return rankings
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"airbnb-scraper/models"
@@ -41,8 +42,9 @@ func (w *CSVWriter) WriteRawListings(listings []*models.RawListing) error {
 
 	// Write header
 	header := []string{
-		"platform", "title", "raw_price", "location",
+		"run_id", "platform", "title", "raw_price", "location", "section", "subtitle",
 		"raw_rating", "url", "description", "scraped_at",
+		"page_hash", "detail_hash", "photo_url", "superhost",
 	}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write CSV header: %w", err)
@@ -51,17 +53,24 @@ func (w *CSVWriter) WriteRawListings(listings []*models.RawListing) error {
 	// Write rows
 	for _, l := range listings {
 		row := []string{
+			l.RunID,
 			l.Platform,
 			l.Title,
 			l.RawPrice,
 			l.Location,
+			l.Section,
+			l.Subtitle,
 			l.RawRating,
 			l.URL,
 			l.Description,
 			l.ScrapedAt.Format(time.RFC3339),
+			l.PageHash,
+			l.DetailHash,
+			l.PhotoURL,
+			strconv.FormatBool(l.Superhost),
 		}
 		if err := writer.Write(row); err != nil {
-			w.logger.Error("Failed to write CSV row for '%s': %v", l.Title, err)
+			w.logger.With("url", l.URL).Error("Failed to write CSV row for '%s': %v", l.Title, err)
 		}
 	}
 
@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"airbnb-scraper/utils"
+)
+
+// Archive entry kinds
+const (
+	ArchiveKindSearch = "search"
+	ArchiveKindDetail = "detail"
+)
+
+// ArchiveEntry records a single fetch of an archived page
+type ArchiveEntry struct {
+	Hash      string    `json:"hash"`
+	Kind      string    `json:"kind"`
+	URL       string    `json:"url"`
+	Section   string    `json:"section,omitempty"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// HTMLArchive stores fetched page bodies on disk, gzip-compressed and addressed
+// by the sha256 of their content. Identical bodies are stored only once; every
+// fetch is still appended to index.jsonl so pages can be traced back to a URL.
+type HTMLArchive struct {
+	root   string
+	logger *utils.Logger
+	mu     sync.Mutex // guards objects and index.jsonl against a concurrent Prune
+}
+
+// NewHTMLArchive creates the archive directory layout under root
+func NewHTMLArchive(root string, logger *utils.Logger) (*HTMLArchive, error) {
+	if err := os.MkdirAll(filepath.Join(root, "objects"), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &HTMLArchive{root: root, logger: logger}, nil
+}
+
+// Put stores body (if not already present) and records the fetch in the index.
+// It returns the content hash that RawListings use to reference the page.
+// The whole store happens under a.mu, so Prune cannot remove the object
+// between the existence check and the index entry that points at it.
+func (a *HTMLArchive) Put(body []byte, entry ArchiveEntry) (string, error) {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	path := a.objectPath(hash)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		// Already archived — refresh mtime so retention counts from the latest fetch
+		now := time.Now()
+		_ = os.Chtimes(path, now, now)
+	} else {
+		if err := a.writeObject(path, body); err != nil {
+			return "", err
+		}
+	}
+
+	entry.Hash = hash
+	if entry.FetchedAt.IsZero() {
+		entry.FetchedAt = time.Now()
+	}
+	if err := a.appendIndex(entry); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// Get returns the decompressed body stored under hash
+func (a *HTMLArchive) Get(hash string) ([]byte, error) {
+	if !validHash(hash) {
+		return nil, fmt.Errorf("invalid archive hash %q", hash)
+	}
+	file, err := os.Open(a.objectPath(hash))
+	if err != nil {
+		return nil, fmt.Errorf("archived page %s not found: %w", hash, err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archived page %s: %w", hash, err)
+	}
+	defer gz.Close()
+
+	return io.ReadAll(gz)
+}
+
+// Entries returns every fetch recorded in the index, oldest first
+func (a *HTMLArchive) Entries() ([]ArchiveEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.readIndex()
+}
+
+// Prune removes archived pages not fetched within maxAge and drops their
+// index entries. It returns the number of pages removed. A maxAge that is
+// not positive keeps every page.
+func (a *HTMLArchive) Prune(maxAge time.Duration) (int, error) {
+	if maxAge <= 0 {
+		return 0, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	err := filepath.Walk(filepath.Join(a.root, "objects"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("failed to prune archive: %w", err)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	entries, err := a.readIndex()
+	if err != nil {
+		return removed, err
+	}
+	var kept []ArchiveEntry
+	for _, e := range entries {
+		if _, err := os.Stat(a.objectPath(e.Hash)); err == nil {
+			kept = append(kept, e)
+		}
+	}
+	if err := a.rewriteIndex(kept); err != nil {
+		return removed, err
+	}
+
+	a.logger.Info("Pruned %d archived pages older than %v", removed, maxAge)
+	return removed, nil
+}
+
+// validHash reports whether hash is a hex-encoded sha256, as Put produces
+func validHash(hash string) bool {
+	if len(hash) != 2*sha256.Size {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// objectPath shards objects by the first two hex characters of the hash
+func (a *HTMLArchive) objectPath(hash string) string {
+	return filepath.Join(a.root, "objects", hash[:2], hash+".html.gz")
+}
+
+func (a *HTMLArchive) indexPath() string {
+	return filepath.Join(a.root, "index.jsonl")
+}
+
+// writeObject compresses body into a temp file and renames it into place so
+// readers never observe a partially written object. Each call gets its own
+// temp file, so concurrent writes of the same page do not clobber each other.
+func (a *HTMLArchive) writeObject(path string, body []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create archive shard: %w", err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return fmt.Errorf("failed to compress page: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress page: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write archived page: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write archived page: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write archived page: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write archived page: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store archived page: %w", err)
+	}
+	return nil
+}
+
+// appendIndex records a fetch; callers hold a.mu
+func (a *HTMLArchive) appendIndex(entry ArchiveEntry) error {
+	file, err := os.OpenFile(a.indexPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open archive index: %w", err)
+	}
+	defer file.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode archive entry: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write archive index: %w", err)
+	}
+	return nil
+}
+
+func (a *HTMLArchive) readIndex() ([]ArchiveEntry, error) {
+	file, err := os.Open(a.indexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive index: %w", err)
+	}
+	defer file.Close()
+
+	var entries []ArchiveEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e ArchiveEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			a.logger.Warn("Skipping malformed archive index line: %v", err)
+			continue
+		}
+		if !validHash(e.Hash) {
+			a.logger.Warn("Skipping archive index line with invalid hash %q", e.Hash)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive index: %w", err)
+	}
+	return entries, nil
+}
+
+func (a *HTMLArchive) rewriteIndex(entries []ArchiveEntry) error {
+	tmp := a.indexPath() + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite archive index: %w", err)
+	}
+
+	enc := json.NewEncoder(file)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to rewrite archive index: %w", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to rewrite archive index: %w", err)
+	}
+	return os.Rename(tmp, a.indexPath())
+}
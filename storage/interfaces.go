@@ -13,3 +13,19 @@ type CleanStorage interface {
 	SaveClean(listings []*models.Listing) error
 	Close() error
 }
+
+// ListingStore is the read side of the clean store, served by the query API.
+// PostgresWriter reads alldata and listing_snapshots; MemoryStore holds
+// listings cleaned in-process.
+type ListingStore interface {
+	// QueryListings returns the page of listings matching q and how many
+	// match in total
+	QueryListings(q *models.ListingQuery) ([]*models.Listing, int, error)
+	// GetListing returns a listing by room ID, or nil when none is stored
+	GetListing(listingID string) (*models.Listing, error)
+	// PriceHistory returns a listing's snapshots, oldest first
+	PriceHistory(listingID string) ([]*models.Snapshot, error)
+	// Runs returns the stored runs, latest first
+	Runs() ([]*models.RunSummary, error)
+	Close()
+}
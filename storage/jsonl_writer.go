@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"airbnb-scraper/models"
+	"airbnb-scraper/utils"
+)
+
+// JSONLWriter appends raw listings to a JSON Lines file. Unlike the CSV, which
+// is rewritten every run, the JSONL file accumulates the raw history of all
+// runs so it can be reprocessed later.
+type JSONLWriter struct {
+	filePath string
+	logger   *utils.Logger
+}
+
+// NewJSONLWriter creates a new JSONLWriter
+func NewJSONLWriter(filePath string, logger *utils.Logger) *JSONLWriter {
+	return &JSONLWriter{filePath: filePath, logger: logger}
+}
+
+// AppendRawListings appends one JSON object per listing to the file
+func (w *JSONLWriter) AppendRawListings(listings []*models.RawListing) error {
+	dir := filepath.Dir(w.filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	file, err := os.OpenFile(w.filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open JSONL file: %w", err)
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	for _, l := range listings {
+		if err := enc.Encode(l); err != nil {
+			w.logger.With("url", l.URL).Error("Failed to write JSONL row for '%s': %v", l.Title, err)
+		}
+	}
+
+	w.logger.Info("Raw listings appended to: %s (%d rows)", w.filePath, len(listings))
+	return nil
+}
+
+// AppendQuarantined appends rejected records, with their reasons, to the file
+func (w *JSONLWriter) AppendQuarantined(records []*models.QuarantinedListing) error {
+	if len(records) == 0 {
+		return nil
+	}
+	dir := filepath.Dir(w.filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	file, err := os.OpenFile(w.filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open JSONL file: %w", err)
+	}
+	defer file.Close()
+
+	enc := json.NewEncoder(file)
+	for _, q := range records {
+		if err := enc.Encode(q); err != nil {
+			w.logger.Error("Failed to write quarantine row for '%s': %v", q.Raw.Title, err)
+		}
+	}
+
+	w.logger.Info("Quarantined records appended to: %s (%d rows)", w.filePath, len(records))
+	return nil
+}
+
+// AppendScheduledRun appends one scheduled run record to the file
+func (w *JSONLWriter) AppendScheduledRun(run *models.ScheduledRun) error {
+	dir := filepath.Dir(w.filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	file, err := os.OpenFile(w.filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open JSONL file: %w", err)
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(run); err != nil {
+		return fmt.Errorf("failed to write scheduled run: %w", err)
+	}
+	return nil
+}
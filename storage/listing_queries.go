@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"airbnb-scraper/models"
+)
+
+// listingSelect reads the columns of listingColumns back, with text columns
+// added after the table was created defaulting to '' for older rows.
+// scanListing scans them in the same order.
+const listingSelect = `SELECT id, COALESCE(listing_id, ''), platform, title, price, COALESCE(currency, ''), local_price,
+	pre_discount_price, discount_pct, total_price, nights, taxes_included,
+	COALESCE(location, ''), rating, review_count, is_new, COALESCE(url, ''), COALESCE(description, ''),
+	scraped_at, COALESCE(run_id, ''),
+	COALESCE(property_type, ''), COALESCE(neighborhood, ''), COALESCE(city, ''), beds, bedrooms,
+	COALESCE(region, ''), COALESCE(country, ''), COALESCE(country_code, ''), latitude, longitude,
+	COALESCE(validation_status, ''), COALESCE(validation_codes, ''),
+	COALESCE(photo_url, ''), COALESCE(photo_hash, ''), COALESCE(cluster_id, ''), is_canonical,
+	COALESCE(description_clean, ''), COALESCE(description_lang, ''), is_superhost, is_outlier, value_score
+	FROM alldata`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanListing(row rowScanner) (*models.Listing, error) {
+	l := &models.Listing{}
+	var propertyType, codes string
+	err := row.Scan(&l.ID, &l.ListingID, &l.Platform, &l.Title, &l.Price, &l.Currency, &l.LocalPrice,
+		&l.PreDiscountPrice, &l.DiscountPct, &l.TotalPrice, &l.Nights, &l.TaxesIncluded,
+		&l.Location, &l.Rating, &l.ReviewCount, &l.IsNew, &l.URL, &l.Description,
+		&l.ScrapedAt, &l.RunID,
+		&propertyType, &l.Neighborhood, &l.City, &l.Beds, &l.Bedrooms,
+		&l.Region, &l.Country, &l.CountryCode, &l.Latitude, &l.Longitude,
+		&l.ValidationStatus, &codes,
+		&l.PhotoURL, &l.PhotoHash, &l.ClusterID, &l.IsCanonical,
+		&l.DescriptionClean, &l.DescriptionLang, &l.IsSuperhost, &l.IsOutlier, &l.ValueScore)
+	if err != nil {
+		return nil, err
+	}
+	l.PropertyType = models.PropertyType(propertyType)
+	if codes != "" {
+		l.ValidationCodes = strings.Split(codes, ",")
+	}
+	return l, nil
+}
+
+// listingFilter builds the WHERE clause of a listing query and its arguments
+func listingFilter(q *models.ListingQuery) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+
+	if q.Location != "" {
+		add("LOWER(COALESCE(NULLIF(city, ''), location)) = LOWER($%d)", q.Location)
+	}
+	if q.PropertyType != "" {
+		add("property_type = $%d", string(q.PropertyType))
+	}
+	if q.MinPrice > 0 {
+		add("price >= $%d", q.MinPrice)
+	}
+	if q.MaxPrice > 0 {
+		add("price <= $%d", q.MaxPrice)
+	}
+	if q.MinRating > 0 {
+		add("rating >= $%d", q.MinRating)
+	}
+	if q.RunID != "" {
+		add("listing_id IN (SELECT listing_id FROM listing_snapshots WHERE run_id = $%d)", q.RunID)
+	}
+
+	if len(conds) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// listingOrder builds the ORDER BY clause of a listing query. Ties, and
+// queries without a sort field, fall back to the listing ID so pages are
+// stable.
+func listingOrder(q *models.ListingQuery) (string, error) {
+	if q.SortBy == "" {
+		return " ORDER BY listing_id", nil
+	}
+	if !isSortField(q.SortBy) {
+		return "", fmt.Errorf("cannot sort by %q", q.SortBy)
+	}
+	dir := "ASC"
+	if q.Descending {
+		dir = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, listing_id", q.SortBy, dir), nil
+}
+
+func isSortField(name string) bool {
+	for _, f := range models.ListingSortFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryListings returns the page of alldata rows matching q and how many
+// match in total. Filtering by run matches the listings snapshotted in it.
+func (w *PostgresWriter) QueryListings(q *models.ListingQuery) ([]*models.Listing, int, error) {
+	where, args := listingFilter(q)
+	order, err := listingOrder(q)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := w.db.QueryRow("SELECT COUNT(*) FROM alldata"+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count listings: %w", err)
+	}
+
+	query := listingSelect + where + order
+	if q.Limit > 0 {
+		args = append(args, q.Limit, q.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	} else if q.Offset > 0 {
+		args = append(args, q.Offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	rows, err := w.db.Query(query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query listings: %w", err)
+	}
+	defer rows.Close()
+
+	var listings []*models.Listing
+	for rows.Next() {
+		l, err := scanListing(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read listing: %w", err)
+		}
+		listings = append(listings, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read listings: %w", err)
+	}
+	return listings, total, nil
+}
+
+// GetListing returns the alldata row of a room ID, or nil when none is stored
+func (w *PostgresWriter) GetListing(listingID string) (*models.Listing, error) {
+	l, err := scanListing(w.db.QueryRow(listingSelect+" WHERE listing_id = $1", listingID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load listing %s: %w", listingID, err)
+	}
+	return l, nil
+}
+
+// PriceHistory returns the snapshots of a listing, oldest first
+func (w *PostgresWriter) PriceHistory(listingID string) ([]*models.Snapshot, error) {
+	rows, err := w.db.Query(`SELECT run_id, listing_id, COALESCE(title, ''), COALESCE(city, ''),
+		price, rating, review_count, is_outlier, scraped_at
+		FROM listing_snapshots WHERE listing_id = $1 ORDER BY scraped_at, run_id`, listingID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query price history: %w", err)
+	}
+	defer rows.Close()
+	return scanSnapshots(rows)
+}
+
+// Runs summarizes the runs in listing_snapshots, latest first
+func (w *PostgresWriter) Runs() ([]*models.RunSummary, error) {
+	rows, err := w.db.Query(`SELECT run_id, MIN(scraped_at), MAX(scraped_at), COUNT(*)
+		FROM listing_snapshots GROUP BY run_id ORDER BY MAX(scraped_at) DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.RunSummary
+	for rows.Next() {
+		r := &models.RunSummary{}
+		if err := rows.Scan(&r.RunID, &r.StartedAt, &r.FinishedAt, &r.Listings); err != nil {
+			return nil, fmt.Errorf("failed to read run: %w", err)
+		}
+		runs = append(runs, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read runs: %w", err)
+	}
+	return runs, nil
+}
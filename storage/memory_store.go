@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"airbnb-scraper/models"
+)
+
+// MemoryStore is a ListingStore over clean listings held in memory, such as
+// runs re-cleaned from the raw history, for serving without PostgreSQL. Like
+// alldata it keeps the latest version of each listing; every version is kept
+// as a snapshot of its run.
+type MemoryStore struct {
+	listings  []*models.Listing // latest version per listing ID, by listing ID
+	byID      map[string]*models.Listing
+	snapshots map[string][]*models.Snapshot // by listing ID, oldest first
+	runs      []*models.RunSummary
+}
+
+// NewMemoryStore indexes clean listings of any number of runs. Listings
+// without a listing ID are left out, as they are from alldata.
+func NewMemoryStore(listings []*models.Listing) *MemoryStore {
+	m := &MemoryStore{
+		byID:      make(map[string]*models.Listing),
+		snapshots: make(map[string][]*models.Snapshot),
+	}
+	runs := make(map[string]*models.RunSummary)
+
+	for _, l := range listings {
+		if l.ListingID == "" {
+			continue
+		}
+		if cur, ok := m.byID[l.ListingID]; !ok || !l.ScrapedAt.Before(cur.ScrapedAt) {
+			m.byID[l.ListingID] = l
+		}
+		if l.RunID == "" {
+			continue
+		}
+
+		city := l.City
+		if city == "" {
+			city = l.Location
+		}
+		m.snapshots[l.ListingID] = append(m.snapshots[l.ListingID], &models.Snapshot{
+			RunID: l.RunID, ListingID: l.ListingID, Title: l.Title, City: city, Price: l.Price,
+			Rating: l.Rating, ReviewCount: l.ReviewCount, IsOutlier: l.IsOutlier, ScrapedAt: l.ScrapedAt,
+		})
+
+		r, ok := runs[l.RunID]
+		if !ok {
+			r = &models.RunSummary{RunID: l.RunID, StartedAt: l.ScrapedAt, FinishedAt: l.ScrapedAt}
+			runs[l.RunID] = r
+		}
+		if l.ScrapedAt.Before(r.StartedAt) {
+			r.StartedAt = l.ScrapedAt
+		}
+		if l.ScrapedAt.After(r.FinishedAt) {
+			r.FinishedAt = l.ScrapedAt
+		}
+		r.Listings++
+	}
+
+	for _, l := range m.byID {
+		m.listings = append(m.listings, l)
+	}
+	sort.Slice(m.listings, func(i, j int) bool {
+		return m.listings[i].ListingID < m.listings[j].ListingID
+	})
+	for _, snaps := range m.snapshots {
+		sort.SliceStable(snaps, func(i, j int) bool {
+			return snaps[i].ScrapedAt.Before(snaps[j].ScrapedAt)
+		})
+	}
+	for _, r := range runs {
+		m.runs = append(m.runs, r)
+	}
+	sort.Slice(m.runs, func(i, j int) bool {
+		return m.runs[i].FinishedAt.After(m.runs[j].FinishedAt)
+	})
+	return m
+}
+
+// QueryListings returns the page of listings matching q and how many match
+// in total, with the same semantics as the PostgreSQL query
+func (m *MemoryStore) QueryListings(q *models.ListingQuery) ([]*models.Listing, int, error) {
+	if q.SortBy != "" && !isSortField(q.SortBy) {
+		return nil, 0, fmt.Errorf("cannot sort by %q", q.SortBy)
+	}
+
+	var matched []*models.Listing
+	for _, l := range m.listings {
+		if m.matches(l, q) {
+			matched = append(matched, l)
+		}
+	}
+	if less := listingLess(q.SortBy); less != nil {
+		sort.SliceStable(matched, func(i, j int) bool {
+			if q.Descending {
+				return less(matched[j], matched[i])
+			}
+			return less(matched[i], matched[j])
+		})
+	}
+
+	total := len(matched)
+	if q.Offset >= total {
+		return nil, total, nil
+	}
+	matched = matched[q.Offset:]
+	if q.Limit > 0 && len(matched) > q.Limit {
+		matched = matched[:q.Limit]
+	}
+	return matched, total, nil
+}
+
+func (m *MemoryStore) matches(l *models.Listing, q *models.ListingQuery) bool {
+	if q.Location != "" {
+		loc := l.City
+		if loc == "" {
+			loc = l.Location
+		}
+		if !strings.EqualFold(loc, q.Location) {
+			return false
+		}
+	}
+	if q.PropertyType != "" && l.PropertyType != q.PropertyType {
+		return false
+	}
+	if q.MinPrice > 0 && l.Price < q.MinPrice {
+		return false
+	}
+	if q.MaxPrice > 0 && l.Price > q.MaxPrice {
+		return false
+	}
+	if q.MinRating > 0 && l.Rating < q.MinRating {
+		return false
+	}
+	if q.RunID != "" {
+		seen := false
+		for _, s := range m.snapshots[l.ListingID] {
+			if s.RunID == q.RunID {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			return false
+		}
+	}
+	return true
+}
+
+// listingLess orders listings by one of models.ListingSortFields, or returns
+// nil to keep them by listing ID
+func listingLess(field string) func(a, b *models.Listing) bool {
+	switch field {
+	case "price":
+		return func(a, b *models.Listing) bool { return a.Price < b.Price }
+	case "rating":
+		return func(a, b *models.Listing) bool { return a.Rating < b.Rating }
+	case "review_count":
+		return func(a, b *models.Listing) bool { return a.ReviewCount < b.ReviewCount }
+	case "value_score":
+		return func(a, b *models.Listing) bool { return a.ValueScore < b.ValueScore }
+	case "scraped_at":
+		return func(a, b *models.Listing) bool { return a.ScrapedAt.Before(b.ScrapedAt) }
+	case "title":
+		return func(a, b *models.Listing) bool { return a.Title < b.Title }
+	}
+	return nil
+}
+
+// GetListing returns the latest version of a listing, or nil when none is held
+func (m *MemoryStore) GetListing(listingID string) (*models.Listing, error) {
+	return m.byID[listingID], nil
+}
+
+// PriceHistory returns the snapshots of a listing, oldest first
+func (m *MemoryStore) PriceHistory(listingID string) ([]*models.Snapshot, error) {
+	return m.snapshots[listingID], nil
+}
+
+// Runs summarizes the runs held, latest first
+func (m *MemoryStore) Runs() ([]*models.RunSummary, error) {
+	return m.runs, nil
+}
+
+// Close does nothing: the listings stay in memory
+func (m *MemoryStore) Close() {}
@@ -2,7 +2,11 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"math"
+	"strconv"
+	"strings"
 	"time"
 
 	"airbnb-scraper/models"
@@ -19,6 +23,16 @@ type PostgresWriter struct {
 
 // NewPostgresWriter creates a new PostgresWriter and pings the DB
 func NewPostgresWriter(connStr string, logger *utils.Logger) (*PostgresWriter, error) {
+	db, err := openDB(connStr)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Connected to PostgreSQL successfully")
+	return &PostgresWriter{db: db, logger: logger}, nil
+}
+
+// openDB opens a connection pool and pings the DB
+func openDB(connStr string) (*sql.DB, error) {
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open DB: %w", err)
@@ -29,11 +43,10 @@ func NewPostgresWriter(connStr string, logger *utils.Logger) (*PostgresWriter, e
 	db.SetConnMaxLifetime(time.Minute * 5)
 
 	if err := db.Ping(); err != nil {
+		_ = db.Close()
 		return nil, fmt.Errorf("failed to ping DB: %w", err)
 	}
-
-	logger.Info("Connected to PostgreSQL successfully")
-	return &PostgresWriter{db: db, logger: logger}, nil
+	return db, nil
 }
 
 // CreateTable creates the alldata table if it doesn't exist, with indexes
@@ -46,15 +59,93 @@ func (w *PostgresWriter) CreateTable() error {
 		price       NUMERIC(10,2) DEFAULT 0,
 		location    TEXT,
 		rating      NUMERIC(4,2) DEFAULT 0,
-		url         TEXT UNIQUE,
+		url         TEXT,
 		description TEXT,
 		scraped_at  TIMESTAMP    NOT NULL DEFAULT NOW()
 	);
 
-	CREATE INDEX IF NOT EXISTS idx_alldata_price    ON alldata (price);
-	CREATE INDEX IF NOT EXISTS idx_alldata_location ON alldata (location);
-	CREATE INDEX IF NOT EXISTS idx_alldata_platform ON alldata (platform);
-	CREATE INDEX IF NOT EXISTS idx_alldata_rating   ON alldata (rating);
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS run_id             TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS listing_id         TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS currency           VARCHAR(3);
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS local_price        NUMERIC(12,2) DEFAULT 0;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS pre_discount_price NUMERIC(12,2) DEFAULT 0;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS discount_pct       NUMERIC(5,2)  DEFAULT 0;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS total_price        NUMERIC(12,2) DEFAULT 0;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS nights             INT           DEFAULT 0;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS taxes_included     BOOLEAN       DEFAULT FALSE;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS review_count       INT           DEFAULT 0;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS is_new             BOOLEAN       DEFAULT FALSE;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS property_type      VARCHAR(30);
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS neighborhood       TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS city               TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS beds               INT           DEFAULT 0;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS bedrooms           INT           DEFAULT 0;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS region             TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS country            TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS country_code       VARCHAR(2);
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS latitude           NUMERIC(9,6)  DEFAULT 0;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS longitude          NUMERIC(9,6)  DEFAULT 0;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS validation_status  VARCHAR(10);
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS validation_codes   TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS photo_url          TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS photo_hash         TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS cluster_id         TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS is_canonical       BOOLEAN       DEFAULT TRUE;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS description_clean  TEXT;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS description_lang   VARCHAR(8);
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS is_superhost       BOOLEAN       DEFAULT FALSE;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS is_outlier         BOOLEAN       DEFAULT FALSE;
+	ALTER TABLE alldata ADD COLUMN IF NOT EXISTS value_score        NUMERIC(5,2)  DEFAULT 0;
+
+	-- Rows written before listing_id existed were keyed on the raw URL:
+	-- backfill their IDs, keep the newest row per listing, then make
+	-- listing_id the unique key instead of url
+	UPDATE alldata
+	SET listing_id = substring(url from '/rooms/(?:plus/|luxury/)?([0-9]+)'),
+	    url        = regexp_replace(url, '[?#].*$', '')
+	WHERE listing_id IS NULL AND url ~ '/rooms/';
+	DELETE FROM alldata a USING alldata b
+	WHERE a.listing_id = b.listing_id AND a.id < b.id;
+	ALTER TABLE alldata DROP CONSTRAINT IF EXISTS alldata_url_key;
+
+	CREATE INDEX IF NOT EXISTS idx_alldata_price         ON alldata (price);
+	CREATE INDEX IF NOT EXISTS idx_alldata_location      ON alldata (location);
+	CREATE INDEX IF NOT EXISTS idx_alldata_platform      ON alldata (platform);
+	CREATE INDEX IF NOT EXISTS idx_alldata_rating        ON alldata (rating);
+	CREATE INDEX IF NOT EXISTS idx_alldata_run_id        ON alldata (run_id);
+	CREATE INDEX IF NOT EXISTS idx_alldata_property_type ON alldata (property_type);
+	CREATE INDEX IF NOT EXISTS idx_alldata_city          ON alldata (city);
+	CREATE INDEX IF NOT EXISTS idx_alldata_country_code  ON alldata (country_code);
+	CREATE INDEX IF NOT EXISTS idx_alldata_cluster_id    ON alldata (cluster_id);
+	CREATE INDEX IF NOT EXISTS idx_alldata_value_score   ON alldata (value_score);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_alldata_listing_id ON alldata (listing_id);
+
+	CREATE TABLE IF NOT EXISTS quarantine (
+		id             SERIAL PRIMARY KEY,
+		run_id         TEXT,
+		listing_id     TEXT,
+		title          TEXT,
+		url            TEXT,
+		reason_codes   TEXT         NOT NULL,
+		issues         JSONB        NOT NULL,
+		raw            JSONB        NOT NULL,
+		quarantined_at TIMESTAMP    NOT NULL DEFAULT NOW()
+	);
+	CREATE INDEX IF NOT EXISTS idx_quarantine_run_id ON quarantine (run_id);
+
+	CREATE TABLE IF NOT EXISTS listing_snapshots (
+		run_id       TEXT          NOT NULL,
+		listing_id   TEXT          NOT NULL,
+		title        TEXT,
+		city         TEXT,
+		price        NUMERIC(10,2) DEFAULT 0,
+		rating       NUMERIC(4,2)  DEFAULT 0,
+		review_count INT           DEFAULT 0,
+		is_outlier   BOOLEAN       DEFAULT FALSE,
+		scraped_at   TIMESTAMP     NOT NULL,
+		PRIMARY KEY (run_id, listing_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_listing_snapshots_scraped_at ON listing_snapshots (scraped_at);
 	`
 	_, err := w.db.Exec(query)
 	if err != nil {
@@ -64,9 +155,301 @@ func (w *PostgresWriter) CreateTable() error {
 	return nil
 }
 
-// BatchInsert inserts clean listings in a single transaction, skipping duplicates
-func (w *PostgresWriter) BatchInsert(listings []*models.Listing) error {
+// listingColumns are the alldata columns written for every listing;
+// listingValues returns the values in the same order
+var listingColumns = []string{
+	"listing_id", "platform", "title", "price", "currency", "local_price",
+	"pre_discount_price", "discount_pct", "total_price", "nights", "taxes_included",
+	"location", "rating", "review_count", "is_new", "url", "description", "scraped_at", "run_id",
+	"property_type", "neighborhood", "city", "beds", "bedrooms",
+	"region", "country", "country_code", "latitude", "longitude",
+	"validation_status", "validation_codes",
+	"photo_url", "photo_hash", "cluster_id", "is_canonical",
+	"description_clean", "description_lang", "is_superhost", "is_outlier", "value_score",
+}
+
+func listingValues(l *models.Listing) []interface{} {
+	return []interface{}{
+		l.ListingID, l.Platform, l.Title, l.Price, l.Currency, l.LocalPrice,
+		l.PreDiscountPrice, l.DiscountPct, l.TotalPrice, l.Nights, l.TaxesIncluded,
+		l.Location, l.Rating, l.ReviewCount, l.IsNew, l.URL, l.Description, l.ScrapedAt, l.RunID,
+		string(l.PropertyType), l.Neighborhood, l.City, l.Beds, l.Bedrooms,
+		l.Region, l.Country, l.CountryCode, l.Latitude, l.Longitude,
+		l.ValidationStatus, strings.Join(l.ValidationCodes, ","),
+		l.PhotoURL, l.PhotoHash, l.ClusterID, l.IsCanonical,
+		l.DescriptionClean, l.DescriptionLang, l.IsSuperhost, l.IsOutlier, l.ValueScore,
+	}
+}
+
+// insertListingSQL builds an INSERT of listingColumns with the given
+// ON CONFLICT (listing_id) action
+func insertListingSQL(onConflict string) string {
+	placeholders := make([]string, len(listingColumns))
+	for i := range listingColumns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	return fmt.Sprintf("INSERT INTO alldata (%s) VALUES (%s) ON CONFLICT (listing_id) %s",
+		strings.Join(listingColumns, ", "), strings.Join(placeholders, ", "), onConflict)
+}
+
+// updateAllSQL is the conflict action that overwrites every listing column
+func updateAllSQL() string {
+	var sets []string
+	for _, col := range listingColumns[1:] {
+		sets = append(sets, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+	}
+	return "DO UPDATE SET " + strings.Join(sets, ", ")
+}
+
+// InsertResult summarizes what a BatchInsert stored
+type InsertResult struct {
+	Inserted int
+	Updated  int // already stored under the same listing ID, now overwritten
+	Stale    int // the stored row was scraped later and is left alone
+	Skipped  int // no listing ID
+	Failed   int
+}
+
+// BatchInsert stores clean listings in a single transaction. A listing already
+// stored under the same listing ID overwrites the row with its latest values,
+// unless the stored row was scraped later.
+func (w *PostgresWriter) BatchInsert(listings []*models.Listing) (*InsertResult, error) {
+	result := &InsertResult{}
 	if len(listings) == 0 {
+		return result, nil
+	}
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	// xmax is 0 on a freshly inserted row and set on an updated one. The
+	// WHERE clause makes the conflict return no row when the stored row is newer.
+	stmt, err := tx.Prepare(insertListingSQL(updateAllSQL()+
+		" WHERE alldata.scraped_at <= EXCLUDED.scraped_at") + " RETURNING (xmax = 0)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, l := range listings {
+		if l.ListingID == "" {
+			w.logger.With("url", l.URL).Warn("Skipping insert for '%s': no listing ID in URL %q", l.Title, l.URL)
+			result.Skipped++
+			continue
+		}
+		var fresh bool
+		execErr := stmt.QueryRow(listingValues(l)...).Scan(&fresh)
+		switch {
+		case execErr == sql.ErrNoRows:
+			result.Stale++
+		case execErr != nil:
+			w.logger.With("listing_id", l.ListingID).Warn("Skipping insert for '%s': %v", l.Title, execErr)
+			result.Failed++
+		case fresh:
+			result.Inserted++
+		default:
+			result.Updated++
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	w.logger.Info("Stored %d listings in PostgreSQL: %d inserted, %d updated, %d stale",
+		len(listings), result.Inserted, result.Updated, result.Stale)
+	return result, nil
+}
+
+// UpsertResult summarizes what an Upsert changed
+type UpsertResult struct {
+	Inserted     int
+	Updated      int
+	Unchanged    int
+	Stale        int            // rows skipped because the stored row is newer
+	FieldChanges map[string]int // column name → number of rows whose value changed
+}
+
+// Add adds the counts of o, such as the result of another batch
+func (r *UpsertResult) Add(o *UpsertResult) {
+	r.Inserted += o.Inserted
+	r.Updated += o.Updated
+	r.Unchanged += o.Unchanged
+	r.Stale += o.Stale
+	for field, n := range o.FieldChanges {
+		r.FieldChanges[field] += n
+	}
+}
+
+// comparedColumn is a column Upsert reports changes for. value renders the
+// listing's field the way PostgreSQL renders the column as text, so stored and
+// incoming values compare at the precision the table keeps.
+type comparedColumn struct {
+	name  string
+	value func(l *models.Listing) string
+}
+
+var comparedColumns = []comparedColumn{
+	{"title", func(l *models.Listing) string { return l.Title }},
+	{"price", func(l *models.Listing) string { return numeric(l.Price) }},
+	{"currency", func(l *models.Listing) string { return l.Currency }},
+	{"local_price", func(l *models.Listing) string { return numeric(l.LocalPrice) }},
+	{"pre_discount_price", func(l *models.Listing) string { return numeric(l.PreDiscountPrice) }},
+	{"discount_pct", func(l *models.Listing) string { return numeric(l.DiscountPct) }},
+	{"total_price", func(l *models.Listing) string { return numeric(l.TotalPrice) }},
+	{"nights", func(l *models.Listing) string { return strconv.Itoa(l.Nights) }},
+	{"taxes_included", func(l *models.Listing) string { return strconv.FormatBool(l.TaxesIncluded) }},
+	{"location", func(l *models.Listing) string { return l.Location }},
+	{"rating", func(l *models.Listing) string { return numeric(l.Rating) }},
+	{"review_count", func(l *models.Listing) string { return strconv.Itoa(l.ReviewCount) }},
+	{"is_new", func(l *models.Listing) string { return strconv.FormatBool(l.IsNew) }},
+	{"description", func(l *models.Listing) string { return l.Description }},
+	{"property_type", func(l *models.Listing) string { return string(l.PropertyType) }},
+	{"neighborhood", func(l *models.Listing) string { return l.Neighborhood }},
+	{"city", func(l *models.Listing) string { return l.City }},
+	{"beds", func(l *models.Listing) string { return strconv.Itoa(l.Beds) }},
+	{"bedrooms", func(l *models.Listing) string { return strconv.Itoa(l.Bedrooms) }},
+	{"region", func(l *models.Listing) string { return l.Region }},
+	{"country", func(l *models.Listing) string { return l.Country }},
+	{"country_code", func(l *models.Listing) string { return l.CountryCode }},
+	{"latitude", func(l *models.Listing) string { return coordinate(l.Latitude) }},
+	{"longitude", func(l *models.Listing) string { return coordinate(l.Longitude) }},
+	{"validation_status", func(l *models.Listing) string { return l.ValidationStatus }},
+	{"validation_codes", func(l *models.Listing) string { return strings.Join(l.ValidationCodes, ",") }},
+	{"photo_hash", func(l *models.Listing) string { return l.PhotoHash }},
+	{"cluster_id", func(l *models.Listing) string { return l.ClusterID }},
+	{"is_canonical", func(l *models.Listing) string { return strconv.FormatBool(l.IsCanonical) }},
+	{"description_clean", func(l *models.Listing) string { return l.DescriptionClean }},
+	{"description_lang", func(l *models.Listing) string { return l.DescriptionLang }},
+	{"is_superhost", func(l *models.Listing) string { return strconv.FormatBool(l.IsSuperhost) }},
+	{"is_outlier", func(l *models.Listing) string { return strconv.FormatBool(l.IsOutlier) }},
+	{"value_score", func(l *models.Listing) string { return numeric(l.ValueScore) }},
+}
+
+// numeric formats a value like a NUMERIC(_,2) column
+func numeric(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', 2, 64)
+}
+
+// coordinate formats a value like a NUMERIC(9,6) column
+func coordinate(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e6)/1e6, 'f', 6, 64)
+}
+
+// Upsert inserts new listings and overwrites existing rows with the given
+// values, in a single transaction. Rows scraped later than the incoming
+// listing are left alone so reprocessing an old run never clobbers fresh data.
+func (w *PostgresWriter) Upsert(listings []*models.Listing) (*UpsertResult, error) {
+	result := &UpsertResult{FieldChanges: make(map[string]int)}
+	if len(listings) == 0 {
+		return result, nil
+	}
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	selects := make([]string, len(comparedColumns))
+	for i, col := range comparedColumns {
+		selects[i] = fmt.Sprintf("COALESCE(%s::text, '')", col.name)
+	}
+	selectStmt, err := tx.Prepare(fmt.Sprintf(
+		"SELECT scraped_at, %s FROM alldata WHERE listing_id = $1", strings.Join(selects, ", ")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer selectStmt.Close()
+
+	upsertStmt, err := tx.Prepare(insertListingSQL(updateAllSQL()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer upsertStmt.Close()
+
+	for _, l := range listings {
+		if l.ListingID == "" {
+			w.logger.With("url", l.URL).Warn("Skipping upsert for '%s': no listing ID in URL %q", l.Title, l.URL)
+			continue
+		}
+
+		var storedAt time.Time
+		stored := make([]string, len(comparedColumns))
+		dest := []interface{}{&storedAt}
+		for i := range stored {
+			dest = append(dest, &stored[i])
+		}
+		exists := true
+		err = selectStmt.QueryRow(l.ListingID).Scan(dest...)
+		if err == sql.ErrNoRows {
+			exists = false
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to look up listing %s: %w", l.ListingID, err)
+		}
+
+		var changed []string
+		if exists {
+			if storedAt.After(l.ScrapedAt) {
+				result.Stale++
+				continue
+			}
+			changed = changedFields(stored, l)
+			if len(changed) == 0 {
+				result.Unchanged++
+				continue
+			}
+		}
+
+		_, err = upsertStmt.Exec(listingValues(l)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upsert '%s': %w", l.Title, err)
+		}
+
+		if !exists {
+			result.Inserted++
+			continue
+		}
+		result.Updated++
+		for _, f := range changed {
+			result.FieldChanges[f]++
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	w.logger.Info("Upserted %d listings: %d inserted, %d updated, %d unchanged, %d stale",
+		len(listings), result.Inserted, result.Updated, result.Unchanged, result.Stale)
+	return result, nil
+}
+
+// changedFields lists the compared columns whose stored value differs from l
+func changedFields(stored []string, l *models.Listing) []string {
+	var changed []string
+	for i, col := range comparedColumns {
+		if stored[i] != col.value(l) {
+			changed = append(changed, col.name)
+		}
+	}
+	return changed
+}
+
+// InsertQuarantined stores rejected records in the quarantine table
+func (w *PostgresWriter) InsertQuarantined(records []*models.QuarantinedListing) error {
+	if len(records) == 0 {
 		return nil
 	}
 
@@ -80,40 +463,38 @@ func (w *PostgresWriter) BatchInsert(listings []*models.Listing) error {
 		}
 	}()
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO alldata (platform, title, price, location, rating, url, description, scraped_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		ON CONFLICT (url) DO NOTHING
-	`)
+	stmt, err := tx.Prepare(`INSERT INTO quarantine
+		(run_id, listing_id, title, url, reason_codes, issues, raw, quarantined_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	inserted := 0
-	for _, l := range listings {
-		_, err = stmt.Exec(
-			l.Platform,
-			l.Title,
-			l.Price,
-			l.Location,
-			l.Rating,
-			l.URL,
-			l.Description,
-			l.ScrapedAt,
-		)
+	for _, q := range records {
+		codes := make([]string, len(q.Issues))
+		for i, is := range q.Issues {
+			codes[i] = is.Code
+		}
+		var issues, raw []byte
+		if issues, err = json.Marshal(q.Issues); err != nil {
+			return fmt.Errorf("failed to encode issues: %w", err)
+		}
+		if raw, err = json.Marshal(q.Raw); err != nil {
+			return fmt.Errorf("failed to encode raw listing: %w", err)
+		}
+		_, err = stmt.Exec(q.RunID, q.ListingID, q.Raw.Title, q.Raw.URL,
+			strings.Join(codes, ","), string(issues), string(raw), q.QuarantinedAt)
 		if err != nil {
-			w.logger.Warn("Skipping insert for '%s': %v", l.Title, err)
-			continue
+			return fmt.Errorf("failed to quarantine '%s': %w", q.Raw.Title, err)
 		}
-		inserted++
 	}
 
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	w.logger.Info("Inserted %d/%d listings into PostgreSQL", inserted, len(listings))
+	w.logger.Info("Quarantined %d records in PostgreSQL", len(records))
 	return nil
 }
 
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"airbnb-scraper/models"
+)
+
+// ReadRawListings loads raw listings previously written by CSVWriter or
+// JSONLWriter. The format is chosen by file extension (.csv or .jsonl).
+func ReadRawListings(path string) ([]*models.RawListing, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return readRawCSV(path)
+	case ".jsonl", ".ndjson":
+		return readRawJSONL(path)
+	default:
+		return nil, fmt.Errorf("unsupported raw file format: %s", path)
+	}
+}
+
+func readRawCSV(path string) ([]*models.RawListing, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	// Map columns by name so files written before a column was added still load
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[name] = i
+	}
+	get := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	var listings []*models.RawListing
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV row: %w", err)
+		}
+		scrapedAt, _ := time.Parse(time.RFC3339, get(row, "scraped_at"))
+		listings = append(listings, &models.RawListing{
+			RunID:       get(row, "run_id"),
+			Platform:    get(row, "platform"),
+			Title:       get(row, "title"),
+			RawPrice:    get(row, "raw_price"),
+			Location:    get(row, "location"),
+			Section:     get(row, "section"),
+			Subtitle:    get(row, "subtitle"),
+			RawRating:   get(row, "raw_rating"),
+			URL:         get(row, "url"),
+			Description: get(row, "description"),
+			ScrapedAt:   scrapedAt,
+			PageHash:    get(row, "page_hash"),
+			DetailHash:  get(row, "detail_hash"),
+			PhotoURL:    get(row, "photo_url"),
+			Superhost:   get(row, "superhost") == "true",
+		})
+	}
+	return listings, nil
+}
+
+func readRawJSONL(path string) ([]*models.RawListing, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open JSONL file: %w", err)
+	}
+	defer file.Close()
+
+	var listings []*models.RawListing
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024) // descriptions can be long
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var l models.RawListing
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return nil, fmt.Errorf("invalid JSONL at %s:%d: %w", path, line, err)
+		}
+		listings = append(listings, &l)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read JSONL file: %w", err)
+	}
+	return listings, nil
+}
+
+// ReadScheduledRuns loads the run history written by
+// JSONLWriter.AppendScheduledRun, oldest first
+func ReadScheduledRuns(path string) ([]*models.ScheduledRun, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open schedule history: %w", err)
+	}
+	defer file.Close()
+
+	var runs []*models.ScheduledRun
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var r models.ScheduledRun
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("invalid JSONL at %s:%d: %w", path, line, err)
+		}
+		runs = append(runs, &r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schedule history: %w", err)
+	}
+	return runs, nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"airbnb-scraper/models"
+)
+
+// InsertSnapshots records each listing as seen in its run. Alldata keeps only
+// the latest values of a listing; snapshots keep every run so trends can be
+// computed. Reprocessing a run overwrites its snapshots with the corrected
+// values. Listings without a run ID (e.g. from old CSVs) are skipped.
+func (w *PostgresWriter) InsertSnapshots(listings []*models.Listing) error {
+	if len(listings) == 0 {
+		return nil
+	}
+
+	tx, err := w.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.Prepare(`INSERT INTO listing_snapshots
+		(run_id, listing_id, title, city, price, rating, review_count, is_outlier, scraped_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (run_id, listing_id) DO UPDATE SET
+			title = EXCLUDED.title, city = EXCLUDED.city, price = EXCLUDED.price,
+			rating = EXCLUDED.rating, review_count = EXCLUDED.review_count,
+			is_outlier = EXCLUDED.is_outlier, scraped_at = EXCLUDED.scraped_at`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	stored := 0
+	for _, l := range listings {
+		if l.RunID == "" || l.ListingID == "" {
+			continue
+		}
+		city := l.City
+		if city == "" {
+			city = l.Location
+		}
+		_, err = stmt.Exec(l.RunID, l.ListingID, l.Title, city, l.Price, l.Rating,
+			l.ReviewCount, l.IsOutlier, l.ScrapedAt)
+		if err != nil {
+			return fmt.Errorf("failed to store snapshot of '%s': %w", l.Title, err)
+		}
+		stored++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	w.logger.Info("Stored %d listing snapshots", stored)
+	return nil
+}
+
+// LoadSnapshots returns every snapshot scraped at or after since, oldest first
+func (w *PostgresWriter) LoadSnapshots(since time.Time) ([]*models.Snapshot, error) {
+	rows, err := w.db.Query(`SELECT run_id, listing_id, COALESCE(title, ''), COALESCE(city, ''),
+		price, rating, review_count, is_outlier, scraped_at
+		FROM listing_snapshots WHERE scraped_at >= $1 ORDER BY scraped_at, run_id`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshots: %w", err)
+	}
+	defer rows.Close()
+	return scanSnapshots(rows)
+}
+
+// scanSnapshots reads rows of the columns selected by LoadSnapshots
+func scanSnapshots(rows *sql.Rows) ([]*models.Snapshot, error) {
+	var snapshots []*models.Snapshot
+	for rows.Next() {
+		s := &models.Snapshot{}
+		if err := rows.Scan(&s.RunID, &s.ListingID, &s.Title, &s.City, &s.Price, &s.Rating,
+			&s.ReviewCount, &s.IsOutlier, &s.ScrapedAt); err != nil {
+			return nil, fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,437 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"airbnb-scraper/models"
+	"airbnb-scraper/utils"
+)
+
+// ErrLeaseLost is returned when a worker reports on a task or run it no
+// longer holds: its lease expired and it was claimed again
+var ErrLeaseLost = errors.New("lease lost")
+
+// TaskQueue is the PostgreSQL work queue shared by scraper workers. Tasks are
+// claimed with SELECT ... FOR UPDATE SKIP LOCKED, so concurrent workers never
+// claim the same task, and held under a lease: a task whose worker stops
+// renewing it is claimed again once the lease expires. A task is tried up to
+// its max attempts, then dead-lettered.
+type TaskQueue struct {
+	db     *sql.DB
+	logger *utils.Logger
+}
+
+// NewTaskQueue connects to the queue's database
+func NewTaskQueue(connStr string, logger *utils.Logger) (*TaskQueue, error) {
+	db, err := openDB(connStr)
+	if err != nil {
+		return nil, err
+	}
+	return &TaskQueue{db: db, logger: logger}, nil
+}
+
+// CreateTables creates the queue tables if they don't exist
+func (q *TaskQueue) CreateTables() error {
+	_, err := q.db.Exec(`
+	CREATE TABLE IF NOT EXISTS queue_runs (
+		run_id            TEXT      PRIMARY KEY,
+		status            TEXT      NOT NULL DEFAULT 'open',
+		leased_by         TEXT,
+		lease_expires_at  TIMESTAMP,
+		assembly_attempts INT       NOT NULL DEFAULT 0,
+		available_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+		error             TEXT,
+		created_at        TIMESTAMP NOT NULL DEFAULT NOW(),
+		finished_at       TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS queue_tasks (
+		id               BIGSERIAL PRIMARY KEY,
+		run_id           TEXT      NOT NULL REFERENCES queue_runs (run_id) ON DELETE CASCADE,
+		kind             TEXT      NOT NULL,
+		name             TEXT      NOT NULL,
+		url              TEXT      NOT NULL,
+		item_limit       INT       NOT NULL DEFAULT 0,
+		status           TEXT      NOT NULL DEFAULT 'pending',
+		attempts         INT       NOT NULL DEFAULT 0,
+		max_attempts     INT       NOT NULL,
+		available_at     TIMESTAMP NOT NULL DEFAULT NOW(),
+		leased_by        TEXT,
+		lease_expires_at TIMESTAMP,
+		last_error       TEXT,
+		result           JSONB,
+		updated_at       TIMESTAMP NOT NULL DEFAULT NOW(),
+		UNIQUE (run_id, kind, url)
+	);
+	CREATE INDEX IF NOT EXISTS idx_queue_tasks_claim  ON queue_tasks (status, available_at);
+	CREATE INDEX IF NOT EXISTS idx_queue_tasks_run_id ON queue_tasks (run_id, status);
+
+	-- Added after the first release of the queue
+	ALTER TABLE queue_runs ADD COLUMN IF NOT EXISTS assembly_attempts INT       NOT NULL DEFAULT 0;
+	ALTER TABLE queue_runs ADD COLUMN IF NOT EXISTS available_at      TIMESTAMP NOT NULL DEFAULT NOW();
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create queue tables: %w", err)
+	}
+	return nil
+}
+
+// CreateRun opens a run with its first tasks
+func (q *TaskQueue) CreateRun(runID string, tasks []*models.Task) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`INSERT INTO queue_runs (run_id) VALUES ($1)`, runID); err != nil {
+		return fmt.Errorf("failed to create run %s: %w", runID, err)
+	}
+	if _, err = insertTasks(tx, tasks); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// insertTasks adds pending tasks, skipping those already queued for the same
+// run, kind and URL, and returns how many were added
+func insertTasks(tx *sql.Tx, tasks []*models.Task) (int, error) {
+	if len(tasks) == 0 {
+		return 0, nil
+	}
+	stmt, err := tx.Prepare(`INSERT INTO queue_tasks (run_id, kind, name, url, item_limit, max_attempts)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (run_id, kind, url) DO NOTHING`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	added := 0
+	for _, t := range tasks {
+		res, err := stmt.Exec(t.RunID, t.Kind, t.Name, t.URL, t.Limit, t.MaxAttempts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to queue %s task %s: %w", t.Kind, t.URL, err)
+		}
+		n, _ := res.RowsAffected()
+		added += int(n)
+	}
+	return added, nil
+}
+
+const taskColumns = `t.id, t.run_id, t.kind, t.name, t.url, t.item_limit, t.status, t.attempts, t.max_attempts,
+	COALESCE(t.last_error, '')`
+
+func scanTask(row rowScanner) (*models.Task, error) {
+	var t models.Task
+	err := row.Scan(&t.ID, &t.RunID, &t.Kind, &t.Name, &t.URL, &t.Limit, &t.Status, &t.Attempts,
+		&t.MaxAttempts, &t.LastError)
+	return &t, err
+}
+
+// Claim leases the next available task to worker: a pending task whose retry
+// delay has passed, or a running one whose lease expired. It returns nil when
+// there is none. Expired tasks on their last attempt are dead-lettered
+// instead.
+func (q *TaskQueue) Claim(worker string, lease time.Duration) (*models.Task, error) {
+	res, err := q.db.Exec(`UPDATE queue_tasks
+		SET status = 'dead', last_error = 'lease expired on the last attempt',
+			leased_by = NULL, lease_expires_at = NULL, updated_at = NOW()
+		WHERE status = 'running' AND lease_expires_at < NOW() AND attempts >= max_attempts`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire leases: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		q.logger.Warn("Dead-lettered %d tasks whose last lease expired", n)
+	}
+
+	t, err := scanTask(q.db.QueryRow(`UPDATE queue_tasks t
+		SET status = 'running', attempts = t.attempts + 1, leased_by = $1,
+			lease_expires_at = NOW() + $2 * INTERVAL '1 second', updated_at = NOW()
+		WHERE t.id = (
+			SELECT id FROM queue_tasks
+			WHERE (status = 'pending' AND available_at <= NOW())
+			   OR (status = 'running' AND lease_expires_at < NOW())
+			ORDER BY available_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING `+taskColumns, worker, lease.Seconds()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim task: %w", err)
+	}
+	return t, nil
+}
+
+// leaseHeld is the condition that worker still holds the attempt of t it
+// claimed; the attempt count fences off a worker whose lease was taken over
+const leaseHeld = `id = $1 AND leased_by = $2 AND attempts = $3 AND status = 'running'`
+
+// ExtendLease renews a claimed task's lease
+func (q *TaskQueue) ExtendLease(t *models.Task, worker string, lease time.Duration) error {
+	res, err := q.db.Exec(`UPDATE queue_tasks
+		SET lease_expires_at = NOW() + $4 * INTERVAL '1 second', updated_at = NOW()
+		WHERE `+leaseHeld, t.ID, worker, t.Attempts, lease.Seconds())
+	return leaseResult(res, err, "extend lease of", t)
+}
+
+// Complete marks a claimed task done with its result and queues the tasks it
+// led to, in one transaction
+func (q *TaskQueue) Complete(t *models.Task, worker string, result []byte, next []*models.Task) error {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.Exec(`UPDATE queue_tasks
+		SET status = 'done', result = $4, last_error = NULL,
+			leased_by = NULL, lease_expires_at = NULL, updated_at = NOW()
+		WHERE `+leaseHeld, t.ID, worker, t.Attempts, string(result))
+	if err = leaseResult(res, err, "complete", t); err != nil {
+		return err
+	}
+	if _, err = insertTasks(tx, next); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Fail records a failed attempt. The task is retried after backoff, or
+// dead-lettered when it has used its last attempt; dead reports which.
+func (q *TaskQueue) Fail(t *models.Task, worker string, cause error, backoff time.Duration) (dead bool, err error) {
+	var status string
+	err = q.db.QueryRow(`UPDATE queue_tasks
+		SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+			available_at = NOW() + $4 * INTERVAL '1 second', last_error = $5,
+			leased_by = NULL, lease_expires_at = NULL, updated_at = NOW()
+		WHERE `+leaseHeld+`
+		RETURNING status`, t.ID, worker, t.Attempts, backoff.Seconds(), cause.Error()).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrLeaseLost
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record failure of task %d: %w", t.ID, err)
+	}
+	return status == models.TaskDead, nil
+}
+
+// Release hands a claimed task back without using up an attempt, for a
+// worker shutting down
+func (q *TaskQueue) Release(t *models.Task, worker string) error {
+	res, err := q.db.Exec(`UPDATE queue_tasks
+		SET status = 'pending', attempts = attempts - 1, available_at = NOW(),
+			leased_by = NULL, lease_expires_at = NULL, updated_at = NOW()
+		WHERE `+leaseHeld, t.ID, worker, t.Attempts)
+	return leaseResult(res, err, "release", t)
+}
+
+func leaseResult(res sql.Result, err error, action string, t *models.Task) error {
+	if err != nil {
+		return fmt.Errorf("failed to %s task %d: %w", action, t.ID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// ClaimFinishedRun leases to worker a run none of whose tasks are pending or
+// running, so it can assemble and process the results. It also takes over
+// runs whose assembling worker's lease expired. It returns the run and the
+// assembly attempt this is, or "" when there is none.
+func (q *TaskQueue) ClaimFinishedRun(worker string, lease time.Duration) (string, int, error) {
+	var runID string
+	var attempt int
+	err := q.db.QueryRow(`UPDATE queue_runs r
+		SET status = 'assembling', leased_by = $1, lease_expires_at = NOW() + $2 * INTERVAL '1 second',
+			assembly_attempts = r.assembly_attempts + 1
+		WHERE r.run_id = (
+			SELECT run_id FROM queue_runs qr
+			WHERE ((qr.status = 'open' AND qr.available_at <= NOW())
+			       OR (qr.status = 'assembling' AND qr.lease_expires_at < NOW()))
+			  AND NOT EXISTS (SELECT 1 FROM queue_tasks t
+			                  WHERE t.run_id = qr.run_id AND t.status IN ('pending', 'running'))
+			ORDER BY qr.created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING r.run_id, r.assembly_attempts`, worker, lease.Seconds()).Scan(&runID, &attempt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to claim finished run: %w", err)
+	}
+	return runID, attempt, nil
+}
+
+// runLeaseHeld matches a run still being assembled by worker ($1 run ID,
+// $2 worker)
+const runLeaseHeld = `run_id = $1 AND leased_by = $2 AND status = 'assembling'`
+
+// ExtendRunLease renews the lease of a run being assembled
+func (q *TaskQueue) ExtendRunLease(runID, worker string, lease time.Duration) error {
+	res, err := q.db.Exec(`UPDATE queue_runs
+		SET lease_expires_at = NOW() + $3 * INTERVAL '1 second'
+		WHERE `+runLeaseHeld, runID, worker, lease.Seconds())
+	return runLeaseResult(res, err, "extend lease of", runID)
+}
+
+// FinishRun marks an assembled run done
+func (q *TaskQueue) FinishRun(runID, worker string) error {
+	res, err := q.db.Exec(`UPDATE queue_runs
+		SET status = 'done', error = NULL, finished_at = NOW(), leased_by = NULL, lease_expires_at = NULL
+		WHERE `+runLeaseHeld, runID, worker)
+	return runLeaseResult(res, err, "finish", runID)
+}
+
+// FailRun records a failed assembly. The run is reopened to be assembled
+// again after backoff, or marked failed once it has been tried maxAttempts
+// times; failed reports which.
+func (q *TaskQueue) FailRun(runID, worker string, cause error, maxAttempts int, backoff time.Duration) (failed bool, err error) {
+	var status string
+	err = q.db.QueryRow(`UPDATE queue_runs
+		SET status = CASE WHEN assembly_attempts >= $3 THEN 'failed' ELSE 'open' END,
+			finished_at = CASE WHEN assembly_attempts >= $3 THEN NOW() END,
+			available_at = NOW() + $4 * INTERVAL '1 second', error = $5,
+			leased_by = NULL, lease_expires_at = NULL
+		WHERE `+runLeaseHeld+`
+		RETURNING status`, runID, worker, maxAttempts, backoff.Seconds(), cause.Error()).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrLeaseLost
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record failure of run %s: %w", runID, err)
+	}
+	return status == models.QueueRunFailed, nil
+}
+
+func runLeaseResult(res sql.Result, err error, action, runID string) error {
+	if err != nil {
+		return fmt.Errorf("failed to %s run %s: %w", action, runID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// RunTasks returns every task of a run with its result, in queue order
+func (q *TaskQueue) RunTasks(runID string) ([]*models.Task, error) {
+	rows, err := q.db.Query(`SELECT `+taskColumns+`, COALESCE(t.result::text, '')
+		FROM queue_tasks t WHERE t.run_id = $1 ORDER BY t.id`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tasks of run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var tasks []*models.Task
+	for rows.Next() {
+		var t models.Task
+		var result string
+		if err := rows.Scan(&t.ID, &t.RunID, &t.Kind, &t.Name, &t.URL, &t.Limit, &t.Status, &t.Attempts,
+			&t.MaxAttempts, &t.LastError, &result); err != nil {
+			return nil, fmt.Errorf("failed to scan task: %w", err)
+		}
+		if result != "" {
+			t.Result = []byte(result)
+		}
+		tasks = append(tasks, &t)
+	}
+	return tasks, rows.Err()
+}
+
+// Runs returns the queue runs with their task counts, latest first: the
+// given run, or the last limit runs when runID is empty
+func (q *TaskQueue) Runs(runID string, limit int) ([]*models.QueueRun, error) {
+	rows, err := q.db.Query(`SELECT r.run_id, r.status, COALESCE(r.error, ''), r.created_at, r.finished_at,
+			COUNT(t.id) FILTER (WHERE t.status = 'pending'),
+			COUNT(t.id) FILTER (WHERE t.status = 'running'),
+			COUNT(t.id) FILTER (WHERE t.status = 'done'),
+			COUNT(t.id) FILTER (WHERE t.status = 'dead')
+		FROM queue_runs r LEFT JOIN queue_tasks t ON t.run_id = r.run_id
+		WHERE $1 = '' OR r.run_id = $1
+		GROUP BY r.run_id
+		ORDER BY r.created_at DESC
+		LIMIT $2`, runID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load queue runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.QueueRun
+	for rows.Next() {
+		var r models.QueueRun
+		var finished sql.NullTime
+		if err := rows.Scan(&r.RunID, &r.Status, &r.Error, &r.CreatedAt, &finished,
+			&r.Pending, &r.Running, &r.Done, &r.Dead); err != nil {
+			return nil, fmt.Errorf("failed to scan queue run: %w", err)
+		}
+		if finished.Valid {
+			r.FinishedAt = &finished.Time
+		}
+		runs = append(runs, &r)
+	}
+	return runs, rows.Err()
+}
+
+// RetryDead puts a run's dead-lettered tasks back in the queue with fresh
+// attempts and reopens the run, so it is assembled again once they finish. A
+// run whose assembly failed is reopened with fresh attempts even when none of
+// its tasks is dead.
+func (q *TaskQueue) RetryDead(runID string) (int, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.Exec(`UPDATE queue_tasks
+		SET status = 'pending', attempts = 0, available_at = NOW(), updated_at = NOW()
+		WHERE run_id = $1 AND status = 'dead'`, runID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to requeue dead tasks of run %s: %w", runID, err)
+	}
+	n, _ := res.RowsAffected()
+	_, err = tx.Exec(`UPDATE queue_runs
+		SET status = 'open', error = NULL, finished_at = NULL, leased_by = NULL, lease_expires_at = NULL,
+			assembly_attempts = 0, available_at = NOW()
+		WHERE run_id = $1 AND status <> 'assembling' AND ($2 > 0 OR status = 'failed')`, runID, n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reopen run %s: %w", runID, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(n), nil
+}
+
+// Close closes the database connection
+func (q *TaskQueue) Close() {
+	if q.db != nil {
+		_ = q.db.Close()
+	}
+}
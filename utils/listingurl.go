@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// roomIDRegex matches the numeric listing ID in paths like /rooms/123,
+// /rooms/plus/123 or /rooms/luxury/123
+var roomIDRegex = regexp.MustCompile(`/rooms/(?:plus/|luxury/)?(\d+)`)
+
+// CanonicalizeListingURL extracts the numeric Airbnb listing ID from a listing
+// URL and returns it with a normalized URL. Query strings (check_in,
+// source_impression_id, ...) and fragments are dropped, the scheme is forced
+// to https and the host is lower-cased, so every link to the same room maps
+// to the same URL. id is empty when the URL does not point at a room.
+func CanonicalizeListingURL(rawURL string) (id string, canonical string) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", ""
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", rawURL
+	}
+
+	// Relative links keep their path only
+	origin := ""
+	if u.Host != "" {
+		origin = "https://" + strings.ToLower(u.Host)
+	}
+
+	if m := roomIDRegex.FindStringSubmatch(u.Path); len(m) == 2 {
+		return m[1], origin + "/rooms/" + m[1]
+	}
+	return "", origin + strings.TrimSuffix(u.Path, "/")
+}
+
+// ListingKey returns the key listings are deduplicated on: the numeric listing
+// ID when the URL has one, otherwise the normalized URL
+func ListingKey(rawURL string) string {
+	id, canonical := CanonicalizeListingURL(rawURL)
+	if id != "" {
+		return id
+	}
+	return canonical
+}
@@ -1,47 +1,266 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-// Logger wraps standard log with level-based output
+// Level is the severity of a log record
+type Level int
+
+// Levels, least severe first
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// Log output formats
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (lv Level) String() string {
+	return levelNames[lv]
+}
+
+// ParseLevel parses debug, info, warn (or warning) and error
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return LevelDebug, nil
+	case "info", "":
+		return LevelInfo, nil
+	case "warn", "warning":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", s)
+}
+
+// LoggerOptions configure OpenLogger. Every field is optional.
+type LoggerOptions struct {
+	Level  string // minimum level written; info by default
+	Format string // text (default) or json
+	File   string // also append every record to this file
+}
+
+// Logger writes leveled records with key/value fields, as text lines or
+// JSON objects. Records below the minimum level are dropped. Loggers derived
+// with With, Tee or ToStderr share the original's lock, so lines written
+// from several goroutines never interleave.
 type Logger struct {
-	info  *log.Logger
-	warn  *log.Logger
-	error *log.Logger
-	debug *log.Logger
+	mu     *sync.Mutex
+	out    io.Writer // debug, info and warn
+	errOut io.Writer // error
+	level  Level
+	json   bool
+	fields []interface{} // key, value, key, value...
+	file   *os.File      // nil without a log file
 }
 
-// NewLogger creates a new structured logger
+// NewLogger creates a text logger at info level writing errors to stderr
+// and everything else to stdout
 func NewLogger() *Logger {
-	flags := log.Lmsgprefix
-	return &Logger{
-		info:  log.New(os.Stdout, "[INFO]  ", flags),
-		warn:  log.New(os.Stdout, "[WARN]  ", flags),
-		error: log.New(os.Stderr, "[ERROR] ", flags),
-		debug: log.New(os.Stdout, "[DEBUG] ", flags),
+	return &Logger{mu: &sync.Mutex{}, out: os.Stdout, errOut: os.Stderr, level: LevelInfo}
+}
+
+// OpenLogger creates a logger like NewLogger with the given level and
+// format, also appending to a log file when one is set
+func OpenLogger(opts LoggerOptions) (*Logger, error) {
+	l := NewLogger()
+	var err error
+	if l.level, err = ParseLevel(opts.Level); err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(opts.Format) {
+	case LogFormatText, "":
+	case LogFormatJSON:
+		l.json = true
+	default:
+		return nil, fmt.Errorf("unknown log format %q (use %s or %s)", opts.Format, LogFormatText, LogFormatJSON)
+	}
+
+	if opts.File != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.File), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %w", err)
+		}
+		file, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		l.file = file
+		l.out = io.MultiWriter(l.out, file)
+		l.errOut = io.MultiWriter(l.errOut, file)
+	}
+	return l, nil
+}
+
+// Close closes the log file, if any
+func (l *Logger) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// With returns a logger that adds the given key/value pairs, such as
+// "run_id", id, to every record
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	if len(keyvals)%2 != 0 {
+		keyvals = append(keyvals, "")
+	}
+	c := *l
+	c.fields = append(append([]interface{}(nil), l.fields...), keyvals...)
+	return &c
+}
+
+// Tee returns a logger that also writes every record to w
+func (l *Logger) Tee(w io.Writer) *Logger {
+	c := *l
+	c.out = io.MultiWriter(l.out, w)
+	c.errOut = io.MultiWriter(l.errOut, w)
+	return &c
+}
+
+// ToStderr returns a logger that writes every level to stderr instead of
+// stdout, and to the log file as before
+func (l *Logger) ToStderr() *Logger {
+	c := *l
+	c.out, c.errOut = io.Writer(os.Stderr), io.Writer(os.Stderr)
+	if l.file != nil {
+		c.out = io.MultiWriter(os.Stderr, l.file)
+		c.errOut = c.out
 	}
+	return &c
 }
 
-func (l *Logger) prefix() string {
-	return fmt.Sprintf(" %s ", time.Now().Format("15:04:05"))
+// Enabled reports whether records of the given level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
 }
 
 func (l *Logger) Info(msg string, args ...interface{}) {
-	l.info.Printf(l.prefix()+msg, args...)
+	l.log(LevelInfo, msg, args)
 }
 
 func (l *Logger) Warn(msg string, args ...interface{}) {
-	l.warn.Printf(l.prefix()+msg, args...)
+	l.log(LevelWarn, msg, args)
 }
 
 func (l *Logger) Error(msg string, args ...interface{}) {
-	l.error.Printf(l.prefix()+msg, args...)
+	l.log(LevelError, msg, args)
 }
 
 func (l *Logger) Debug(msg string, args ...interface{}) {
-	l.debug.Printf(l.prefix()+msg, args...)
-}
\ No newline at end of file
+	l.log(LevelDebug, msg, args)
+}
+
+func (l *Logger) log(level Level, msg string, args []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	msg = fmt.Sprintf(msg, args...)
+	now := time.Now()
+
+	var line []byte
+	if l.json {
+		line = l.jsonRecord(now, level, msg)
+	} else {
+		line = l.textRecord(now, level, msg)
+	}
+
+	w := l.out
+	if level == LevelError {
+		w = l.errOut
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = w.Write(line)
+}
+
+// textRecord formats "[INFO]   15:04:05 message key=value ..."
+func (l *Logger) textRecord(now time.Time, level Level, msg string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-8s %s %s", "["+strings.ToUpper(level.String())+"]", now.Format("15:04:05"), strings.TrimRight(msg, "\n"))
+	for i := 0; i < len(l.fields); i += 2 {
+		b.WriteString(" ")
+		b.WriteString(fieldKey(l.fields[i]))
+		b.WriteString("=")
+		b.WriteString(textValue(l.fields[i+1]))
+	}
+	b.WriteString("\n")
+	return []byte(b.String())
+}
+
+// jsonRecord formats {"time":...,"level":...,"msg":...,"key":value,...}
+func (l *Logger) jsonRecord(now time.Time, level Level, msg string) []byte {
+	var b strings.Builder
+	b.WriteString(`{"time":`)
+	writeJSON(&b, now.Format(time.RFC3339Nano))
+	b.WriteString(`,"level":`)
+	writeJSON(&b, level.String())
+	b.WriteString(`,"msg":`)
+	writeJSON(&b, strings.TrimRight(msg, "\n"))
+	for i := 0; i < len(l.fields); i += 2 {
+		b.WriteString(",")
+		writeJSON(&b, fieldKey(l.fields[i]))
+		b.WriteString(":")
+		writeJSON(&b, jsonValue(l.fields[i+1]))
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+func fieldKey(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	return fmt.Sprint(k)
+}
+
+// jsonValue turns errors, durations and other Stringers into strings so
+// they do not encode as empty objects
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+// textValue quotes values that would be ambiguous unquoted
+func textValue(v interface{}) string {
+	s := fmt.Sprint(jsonValue(v))
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func writeJSON(b *strings.Builder, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+	}
+	b.Write(data)
+}
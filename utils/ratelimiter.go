@@ -19,8 +19,10 @@ func NewRateLimiter(delayMs int) *RateLimiter {
 	}
 }
 
-// Wait blocks until enough time has passed since the last request
-func (r *RateLimiter) Wait() {
+// Wait blocks until enough time has passed since the last request and
+// returns how long it blocked
+func (r *RateLimiter) Wait() time.Duration {
+	start := time.Now()
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -30,4 +32,5 @@ func (r *RateLimiter) Wait() {
 		time.Sleep(r.delay - elapsed)
 	}
 	r.lastCall = time.Now()
+	return r.lastCall.Sub(start)
 }
\ No newline at end of file
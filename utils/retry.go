@@ -11,12 +11,12 @@ func RetryWithBackoff(maxRetries int, fn func() error, logger *Logger) error {
 	for attempt := 0; attempt < maxRetries; attempt++ {
 		if attempt > 0 {
 			backoff := time.Duration(attempt*attempt) * time.Second
-			logger.Warn("Retrying (attempt %d/%d) after %v...", attempt+1, maxRetries, backoff)
+			logger.With("attempt", attempt+1).Warn("Retrying (attempt %d/%d) after %v...", attempt+1, maxRetries, backoff)
 			time.Sleep(backoff)
 		}
 		if err := fn(); err != nil {
 			lastErr = err
-			logger.Error("Attempt %d failed: %v", attempt+1, err)
+			logger.With("attempt", attempt+1).Error("Attempt %d failed: %v", attempt+1, err)
 			continue
 		}
 		return nil
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"airbnb-scraper/config"
+	"airbnb-scraper/queue"
+	"airbnb-scraper/utils"
+)
+
+// runWorker claims and runs queued tasks until interrupted. A task in
+// progress is handed back to the queue for another worker.
+func runWorker(cfg *config.Config, logger *utils.Logger, args []string) {
+	fs := flag.NewFlagSet("worker", flag.ExitOnError)
+	id := fs.String("id", "", "worker name in task leases (default: host-pid)")
+	exitWhenIdle := fs.Bool("exit-when-idle", false, "exit once no task is left instead of polling")
+	_ = fs.Parse(args)
+
+	q := openTaskQueue(cfg, logger)
+	defer q.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	worker, err := queue.NewWorker(cfg, q, logger, *id)
+	if err != nil {
+		logger.Error("%v", err)
+		os.Exit(2)
+	}
+	logger.Info("Worker %s started", worker.ID())
+	if err := worker.Run(ctx, *exitWhenIdle); err != nil && ctx.Err() == nil {
+		logger.Error("%v", err)
+		os.Exit(1)
+	}
+	logger.Info("Worker %s stopped", worker.ID())
+}